@@ -0,0 +1,128 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/draculaas/1brc/agg"
+	"github.com/draculaas/1brc/common"
+	"github.com/draculaas/1brc/sol3"
+	"github.com/draculaas/1brc/sol4"
+	"github.com/draculaas/1brc/solver"
+	"github.com/stretchr/testify/assert"
+)
+
+// naiveAggregate computes fn over the values of every station in file
+// order, in tenths, and formats it like RunAggregate.
+func naiveAggregate(t *testing.T, fileName string, fn func(values []int64) string) string {
+	f, err := os.Open(fileName)
+	assert.NoError(t, err)
+	defer f.Close()
+	values := make(map[string][]int64)
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		name, temp, _ := strings.Cut(sc.Text(), ";")
+		v, err := strconv.ParseInt(strings.Replace(temp, ".", "", 1), 10, 64)
+		assert.NoError(t, err)
+		values[name] = append(values[name], v)
+	}
+	names := make([]string, 0, len(values))
+	for name := range values {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	res := make([]string, 0, len(names))
+	for _, name := range names {
+		res = append(res, name+"="+fn(values[name]))
+	}
+	return "{" + strings.Join(res, ", ") + "}\n"
+}
+
+func Test_TestAggregate(t *testing.T) {
+	fileNames := []string{writeMeasurements(t, randomNames(300), 30_000)}
+	for _, name := range find("./test_cases", ".txt") {
+		fileNames = append(fileNames, name+".txt")
+	}
+
+	aggregates := map[string]func(values []int64) string{
+		"count-above:20.5": func(values []int64) string {
+			n := 0
+			for _, v := range values {
+				if v > 205 {
+					n++
+				}
+			}
+			return strconv.Itoa(n)
+		},
+		"first-last": func(values []int64) string {
+			return fmt.Sprintf("%.1f/%.1f", float64(values[0])/10, float64(values[len(values)-1])/10)
+		},
+		"distinct": func(values []int64) string {
+			seen := make(map[int64]bool)
+			for _, v := range values {
+				seen[v] = true
+			}
+			return strconv.Itoa(len(seen))
+		},
+	}
+
+	for _, fileName := range fileNames {
+		src, err := common.OpenSource("mmap", fileName)
+		assert.NoError(t, err)
+		opts := common.Options{Workers: 3, ChunkSize: 4096}
+
+		// the generic loops compute what the hand written ones do
+		assert.Equal(t, sol3.RunSource(src, opts), sol3.RunAggregate(src, opts, agg.MinMeanMax{}), fileName)
+		assert.Equal(t, sol4.RunSource(src, opts), sol4.RunAggregate(src, opts, agg.MinMeanMax{}), fileName)
+
+		for _, sol := range solver.AggregateSolutions {
+			for spec, fn := range aggregates {
+				run, err := solver.LookupAggregate(sol, spec)
+				assert.NoError(t, err)
+				assert.Equal(t, naiveAggregate(t, fileName, fn), run(src, opts), "%s %s %s", sol, spec, fileName)
+			}
+		}
+		src.Close()
+	}
+
+	_, err := solver.LookupAggregate("sol4", "count-above")
+	assert.Error(t, err)
+	_, err = solver.LookupAggregate("sol5", "distinct")
+	assert.Error(t, err)
+}
+
+// BenchmarkAggregate compares the hand written loops of sol3 and sol4 with
+// the generic ones computing the same aggregate and the other aggregates.
+func BenchmarkAggregate(b *testing.B) {
+	fileName := writeMeasurements(b, randomNames(10_000), 2_000_000)
+	runs := map[string]solver.SourceFunc{"sol3": sol3.RunSource, "sol4": sol4.RunSource}
+	var names []string
+	for _, sol := range solver.AggregateSolutions {
+		names = append(names, sol)
+		for _, spec := range []string{"min-mean-max", "count-above:20", "first-last", "distinct"} {
+			run, err := solver.LookupAggregate(sol, spec)
+			if err != nil {
+				b.Fatal(err)
+			}
+			runs[sol+"/"+spec] = run
+			names = append(names, sol+"/"+spec)
+		}
+	}
+	for _, name := range names {
+		b.Run(name, func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				src, err := common.OpenSource("mmap", fileName)
+				if err != nil {
+					b.Fatal(err)
+				}
+				runs[name](src, common.Options{})
+				src.Close()
+			}
+		})
+	}
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"net/rpc"
+	"os"
+	"runtime"
+	"strconv"
+	"strings"
+
+	"github.com/draculaas/1brc/cluster"
+	"github.com/draculaas/1brc/common"
+)
+
+func workerCmd(args []string) error {
+	fs := newFlagSet("worker", "worker [-listen addr] [-workers n] [-parent]")
+	listen := fs.String("listen", "127.0.0.1:0", "address to serve coordinators on, the address chosen is printed")
+	workers := fs.Int("workers", 0, "goroutines aggregating every range, 0 keeps the default of sol4")
+	parent := fs.Bool("parent", false, "exit once stdin is closed, set by the coordinator on the workers it starts")
+	fs.Parse(args)
+
+	l, err := net.Listen("tcp", *listen)
+	if err != nil {
+		return err
+	}
+	fmt.Println(l.Addr())
+	if *parent {
+		go func() {
+			io.Copy(io.Discard, os.Stdin)
+			os.Exit(0)
+		}()
+	}
+	return cluster.Serve(l, &cluster.Worker{Options: common.Options{Workers: *workers}})
+}
+
+func coordinateCmd(args []string) error {
+	fs := newFlagSet("coordinate", "coordinate [-procs n] [-join addr,...] <file>...")
+	procs := fs.Int("procs", runtime.NumCPU(), "worker processes to start on this machine")
+	join := fs.String("join", "", "comma separated addresses of more workers, started with the worker command")
+	workers := fs.Int("workers", 0, "goroutines aggregating every range in the started workers, 0 keeps the default of sol4")
+	rangeSize := fs.Int64("range-size", 0, "size in bytes of the ranges handed to the workers, 0 keeps the default")
+	partial := fs.String("partial", "", "also write the merged result to this partial `file`")
+	report := fs.Bool("report", false, "write lost workers and the ranges of every worker to stderr")
+	fs.Parse(args)
+	if fs.NArg() == 0 {
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	exe, err := os.Executable()
+	if err != nil {
+		return err
+	}
+	started, err := cluster.Spawn(*procs, exe, "worker", "-parent", "-workers", strconv.Itoa(*workers))
+	if err != nil {
+		return err
+	}
+	var clients []*rpc.Client
+	for _, p := range started {
+		defer p.Close()
+		clients = append(clients, p.Client)
+	}
+	if *join != "" {
+		for _, addr := range strings.Split(*join, ",") {
+			client, err := rpc.Dial("tcp", addr)
+			if err != nil {
+				return err
+			}
+			defer client.Close()
+			clients = append(clients, client)
+		}
+	}
+
+	c := cluster.Coordinator{RangeSize: *rangeSize}
+	if *report {
+		c.Report = os.Stderr
+	}
+	r, err := c.Run(fs.Args(), clients)
+	if err != nil {
+		return err
+	}
+	if *partial != "" {
+		if err := common.WritePartialFile(*partial, r); err != nil {
+			return err
+		}
+	}
+	fmt.Print(r)
+	return nil
+}
@@ -0,0 +1,142 @@
+package cluster
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net/rpc"
+	"os"
+	"sync"
+
+	"github.com/draculaas/1brc/common"
+	"github.com/draculaas/1brc/sol4"
+)
+
+// DefaultRangeSize is the size of the ranges handed to the workers unless
+// told otherwise.
+const DefaultRangeSize = 64 << 20
+
+// ErrNoWorkers is returned by Run once every worker is lost with ranges left.
+var ErrNoWorkers = errors.New("cluster: no worker left")
+
+// Coordinator cuts files into ranges and hands them to workers, giving the
+// range of a worker that fails to the others.
+type Coordinator struct {
+	// RangeSize is the size in bytes of the ranges, 0 picks
+	// DefaultRangeSize.
+	RangeSize int64
+	// Report, when set, receives a line for every worker lost and the
+	// number of ranges every worker aggregated.
+	Report io.Writer
+}
+
+// Run solves files on workers and returns the merged stations of all of
+// them. A worker whose connection fails is dropped and its range handed to
+// another one; Run fails once all workers are lost or a worker returns an
+// error.
+func (c *Coordinator) Run(files []string, workers []*rpc.Client) (common.Result, error) {
+	if len(workers) == 0 {
+		return nil, ErrNoWorkers
+	}
+	rangeSize := c.RangeSize
+	if rangeSize <= 0 {
+		rangeSize = DefaultRangeSize
+	}
+
+	var tasks []Task
+	for _, f := range files {
+		info, err := os.Stat(f)
+		if err != nil {
+			return nil, err
+		}
+		for _, s := range sol4.Splits(info.Size(), rangeSize) {
+			tasks = append(tasks, Task{File: f, Split: s})
+		}
+	}
+	// room for every task, so a lost range goes back without blocking
+	queue := make(chan Task, len(tasks))
+	for _, t := range tasks {
+		queue <- t
+	}
+
+	// completed and failed have room for every send, so no worker blocks
+	// once Run returned
+	type completion struct {
+		worker int
+		result common.Result
+	}
+	completed := make(chan completion, len(tasks))
+	failed := make(chan error, len(workers))
+	stop := make(chan struct{})
+	defer close(stop)
+	var (
+		mu   sync.Mutex
+		lost int
+	)
+
+	for i, client := range workers {
+		go func(i int, client *rpc.Client) {
+			for {
+				// a stopped run takes no more ranges, even with some left
+				var t Task
+				select {
+				case <-stop:
+					return
+				default:
+				}
+				select {
+				case t = <-queue:
+				case <-stop:
+					return
+				}
+
+				var partial []byte
+				err := client.Call("Worker.Aggregate", t, &partial)
+				if _, ok := err.(rpc.ServerError); ok {
+					failed <- fmt.Errorf("%s at %d: %w", t.File, t.Split.Offset, err)
+					return
+				}
+				if err != nil {
+					// the worker is gone, another one takes its range
+					queue <- t
+					mu.Lock()
+					lost++
+					if c.Report != nil {
+						fmt.Fprintf(c.Report, "cluster worker %d lost on %s at %d: %v\n", i, t.File, t.Split.Offset, err)
+					}
+					if lost == len(workers) {
+						failed <- ErrNoWorkers
+					}
+					mu.Unlock()
+					return
+				}
+				r, err := common.ReadPartial(bytes.NewReader(partial))
+				if err != nil {
+					failed <- fmt.Errorf("%s at %d: %w", t.File, t.Split.Offset, err)
+					return
+				}
+				completed <- completion{i, r}
+			}
+		}(i, client)
+	}
+
+	results := make([]common.Result, 0, len(tasks))
+	done := make([]int, len(workers))
+	for len(results) < len(tasks) {
+		select {
+		case d := <-completed:
+			results = append(results, d.result)
+			done[d.worker]++
+		case err := <-failed:
+			return nil, err
+		}
+	}
+
+	if c.Report != nil {
+		for i, n := range done {
+			fmt.Fprintf(c.Report, "cluster worker %d: %d ranges\n", i, n)
+		}
+	}
+	return common.Merge(results...), nil
+}
@@ -0,0 +1,89 @@
+package cluster
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net/rpc"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// Process is a worker process started by Spawn.
+type Process struct {
+	*rpc.Client
+	// Addr is the address the worker serves on.
+	Addr  string
+	cmd   *exec.Cmd
+	stdin io.Closer
+}
+
+// Spawn starts n worker processes running exe with args and connects to
+// them. The workers must print the address they serve on as the first line
+// of their stdout and exit once their stdin is closed, like the worker
+// command of the driver does.
+func Spawn(n int, exe string, args ...string) ([]*Process, error) {
+	procs := make([]*Process, 0, n)
+	for i := 0; i < n; i++ {
+		p, err := spawn(exe, args)
+		if err != nil {
+			for _, p := range procs {
+				p.Kill()
+			}
+			return nil, err
+		}
+		procs = append(procs, p)
+	}
+	return procs, nil
+}
+
+func spawn(exe string, args []string) (*Process, error) {
+	cmd := exec.Command(exe, args...)
+	cmd.Stderr = os.Stderr
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+
+	p := &Process{cmd: cmd, stdin: stdin}
+	out := bufio.NewReader(stdout)
+	addr, err := out.ReadString('\n')
+	if err != nil {
+		p.Kill()
+		return nil, fmt.Errorf("cluster: worker exited before telling its address: %w", err)
+	}
+	go io.Copy(io.Discard, out)
+
+	p.Addr = strings.TrimSpace(addr)
+	if p.Client, err = rpc.Dial("tcp", p.Addr); err != nil {
+		p.Kill()
+		return nil, err
+	}
+	return p, nil
+}
+
+// Kill stops the worker at once, as if it crashed.
+func (p *Process) Kill() error {
+	err := p.cmd.Process.Kill()
+	p.cmd.Wait()
+	p.stdin.Close()
+	if p.Client != nil {
+		p.Client.Close()
+	}
+	return err
+}
+
+// Close disconnects from the worker and waits for it to exit.
+func (p *Process) Close() error {
+	p.Client.Close()
+	p.stdin.Close()
+	return p.cmd.Wait()
+}
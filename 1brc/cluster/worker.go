@@ -0,0 +1,59 @@
+// Package cluster solves files on several worker processes: a coordinator
+// cuts the files into byte ranges, hands them to the workers over net/rpc and
+// merges the partial results they send back.
+package cluster
+
+import (
+	"bytes"
+	"net"
+	"net/rpc"
+
+	"github.com/draculaas/1brc/common"
+	"github.com/draculaas/1brc/sol4"
+)
+
+// Task is a byte range of a file for a worker to aggregate.
+type Task struct {
+	File  string
+	Split sol4.Split
+}
+
+// Worker aggregates the tasks of coordinators, see Serve.
+type Worker struct {
+	// Options tune the sol4 parse loop running on every range.
+	Options common.Options
+}
+
+// Aggregate aggregates the lines starting in the range of t and replies with
+// their partial result, see common.WritePartial.
+func (w *Worker) Aggregate(t Task, partial *[]byte) error {
+	src, err := common.OpenSource("pread", t.File)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	var buf bytes.Buffer
+	r := sol4.AggregateRange(src, t.Split.Offset, t.Split.Len, w.Options)
+	if err := common.WritePartial(&buf, r); err != nil {
+		return err
+	}
+	*partial = buf.Bytes()
+	return nil
+}
+
+// Serve serves w over net/rpc to the coordinators connecting to l, until l
+// fails or is closed.
+func Serve(l net.Listener, w *Worker) error {
+	s := rpc.NewServer()
+	if err := s.RegisterName("Worker", w); err != nil {
+		return err
+	}
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			return err
+		}
+		go s.ServeConn(conn)
+	}
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"bytes"
+	"log"
+	"net/rpc"
+	"os"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/draculaas/1brc/cluster"
+	"github.com/draculaas/1brc/common"
+	"github.com/draculaas/1brc/sol1"
+	"github.com/stretchr/testify/assert"
+)
+
+// TestMain runs the worker command instead of the tests in the worker
+// processes the cluster tests start from the test binary.
+func TestMain(m *testing.M) {
+	if os.Getenv("BRC_TEST_WORKER") != "" {
+		if err := workerCmd(os.Args[1:]); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+	// the results must not depend on the tuning file of the machine
+	os.Setenv(common.TuningEnv, common.TuningOff)
+	os.Exit(m.Run())
+}
+
+// spawnWorkers starts n worker processes of the test binary.
+func spawnWorkers(t *testing.T, n int) []*cluster.Process {
+	t.Setenv("BRC_TEST_WORKER", "1")
+	procs, err := cluster.Spawn(n, os.Args[0], "-parent", "-workers", "2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		for _, p := range procs {
+			p.Kill()
+		}
+	})
+	return procs
+}
+
+func clients(procs []*cluster.Process) []*rpc.Client {
+	res := make([]*rpc.Client, len(procs))
+	for i, p := range procs {
+		res[i] = p.Client
+	}
+	return res
+}
+
+// wantMerged solves every file with sol1 and merges the results.
+func wantMerged(t *testing.T, files ...string) string {
+	var results []common.Result
+	for _, f := range files {
+		var partial bytes.Buffer
+		sol1.RunWith(f, common.Options{Partial: &partial})
+		r, err := common.ReadPartial(&partial)
+		assert.NoError(t, err)
+		results = append(results, r)
+	}
+	return common.Merge(results...).String()
+}
+
+func Test_TestCluster(t *testing.T) {
+	a := writeMeasurements(t, randomNames(300), 20_000)
+	b := writeMeasurements(t, randomNames(1_000), 30_000)
+	want := wantMerged(t, a, b)
+	procs := spawnWorkers(t, 3)
+
+	c := cluster.Coordinator{RangeSize: 16 << 10}
+	got, err := c.Run([]string{a, b}, clients(procs))
+	assert.NoError(t, err)
+	assert.Equal(t, want, got.String())
+
+	// a crashed worker fails its next range, which goes to the others
+	var report bytes.Buffer
+	c.Report = &report
+	procs[1].Kill()
+	got, err = c.Run([]string{a, b}, clients(procs))
+	assert.NoError(t, err)
+	assert.Equal(t, want, got.String())
+	assert.Contains(t, report.String(), "cluster worker 1 lost")
+
+	procs[0].Kill()
+	procs[2].Kill()
+	goroutines := runtime.NumGoroutine()
+	_, err = c.Run([]string{a, b}, clients(procs))
+	assert.ErrorIs(t, err, cluster.ErrNoWorkers)
+	// nothing of a failed run is left running
+	for deadline := time.Now().Add(time.Second); runtime.NumGoroutine() > goroutines && time.Now().Before(deadline); {
+		time.Sleep(10 * time.Millisecond)
+	}
+	assert.LessOrEqual(t, runtime.NumGoroutine(), goroutines)
+
+	_, err = c.Run([]string{a}, nil)
+	assert.ErrorIs(t, err, cluster.ErrNoWorkers)
+}
+
+// Test_TestClusterCrash kills a worker while it aggregates its ranges.
+func Test_TestClusterCrash(t *testing.T) {
+	fileName := writeMeasurements(t, randomNames(1_000), 200_000)
+	want := wantMerged(t, fileName)
+	procs := spawnWorkers(t, 2)
+
+	var report bytes.Buffer
+	c := cluster.Coordinator{RangeSize: 4 << 10, Report: &report}
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		got, err := c.Run([]string{fileName}, clients(procs))
+		assert.NoError(t, err)
+		assert.Equal(t, want, got.String())
+	}()
+	procs[0].Kill()
+	<-done
+	assert.Contains(t, report.String(), "cluster worker 0 lost")
+}
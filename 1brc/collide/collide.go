@@ -0,0 +1,51 @@
+// Package collide builds valid station names that defeat the hash tables of
+// the solutions, so changes to a hash function can be judged against a worst
+// case.
+package collide
+
+import "fmt"
+
+// Sol3 returns n names sharing the same first 8 bytes. sol3 hashes only the
+// first word of a name, so all of them get the same hash and end up in one
+// chain that is walked with a string comparison on every row.
+func Sol3(n int) []string {
+	names := make([]string, n)
+	for i := range names {
+		names[i] = "Collider" + suffix(i, 1)
+	}
+	return names
+}
+
+// Sol4 returns n names of 8 bytes starting with the same two bytes. The low 16
+// bits of sol4's xor/multiply hash only depend on the first two bytes of every
+// word, so all the names start probing at the same slot of the 65536 slot
+// table and form one long cluster.
+func Sol4(n int) []string {
+	names := make([]string, n)
+	for i := range names {
+		names[i] = "Zq" + suffix(i, 6)
+	}
+	return names
+}
+
+// ByName returns the names attacking the named solution.
+func ByName(sol string, n int) ([]string, error) {
+	switch sol {
+	case "sol3":
+		return Sol3(n), nil
+	case "sol4":
+		return Sol4(n), nil
+	}
+	return nil, fmt.Errorf("no collisions known for %q", sol)
+}
+
+const alphabet = "abcdefghijklmnopqrstuvwxyz"
+
+// suffix spells i in base 26 with at least width letters.
+func suffix(i, width int) string {
+	var b []byte
+	for ; i > 0 || len(b) < width; i /= len(alphabet) {
+		b = append(b, alphabet[i%len(alphabet)])
+	}
+	return string(b)
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"math/rand"
+	"testing"
+
+	"github.com/draculaas/1brc/collide"
+	"github.com/draculaas/1brc/sol1"
+	"github.com/draculaas/1brc/sol3"
+	"github.com/draculaas/1brc/sol4"
+	"github.com/stretchr/testify/assert"
+)
+
+// randomNames returns n distinct names that spread well under every hash.
+func randomNames(n int) []string {
+	rng := rand.New(rand.NewSource(1))
+	seen := make(map[string]bool, n)
+	names := make([]string, 0, n)
+	for len(names) < n {
+		b := make([]byte, 6+rng.Intn(10))
+		for i := range b {
+			b[i] = byte('a' + rng.Intn(26))
+		}
+		if !seen[string(b)] {
+			seen[string(b)] = true
+			names = append(names, string(b))
+		}
+	}
+	return names
+}
+
+// sol4Twins are two names with the same 64-bit hash under sol4, which a
+// table keyed by that hash alone takes for one station.
+var sol4Twins = []string{"StationsCollider", "StationoCollideV"}
+
+func Test_TestCollisions(t *testing.T) {
+	assert.Equal(t, sol4.HashName([]byte(sol4Twins[0])), sol4.HashName([]byte(sol4Twins[1])))
+	for _, sol := range []string{"sol3", "sol4"} {
+		names, err := collide.ByName(sol, 1000)
+		assert.NoError(t, err)
+		fileName := writeMeasurements(t, names, 10_000)
+		want := sol1.Run(fileName)
+
+		t.Run(sol+"/sol3", func(t *testing.T) {
+			assert.Equal(t, want, sol3.Run(fileName))
+		})
+		t.Run(sol+"/sol4", func(t *testing.T) {
+			assert.Equal(t, want, sol4.Run(fileName))
+		})
+	}
+}
+
+func BenchmarkCollisions(b *testing.B) {
+	const stations, rows = 2_000, 500_000
+
+	inputs := []struct {
+		name  string
+		names []string
+	}{
+		{"random", randomNames(stations)},
+		{"sol3-collisions", collide.Sol3(stations)},
+		{"sol4-collisions", collide.Sol4(stations)},
+	}
+	solutions := []struct {
+		name string
+		run  func(string) string
+	}{
+		{"sol3", sol3.Run},
+		{"sol4", sol4.Run},
+	}
+
+	for _, in := range inputs {
+		fileName := writeMeasurements(b, in.names, rows)
+		for _, sol := range solutions {
+			b.Run(in.name+"/"+sol.name, func(b *testing.B) {
+				for i := 0; i < b.N; i++ {
+					sol.run(fileName)
+				}
+			})
+		}
+	}
+}
@@ -0,0 +1,100 @@
+package columnar
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"runtime"
+	"sync"
+	"sync/atomic"
+
+	"github.com/draculaas/1brc/common"
+)
+
+// RunWith solves the columnar file fileName on opts.Workers goroutines, see
+// Compile.
+func RunWith(fileName string, opts common.Options) string {
+	f, err := Open(fileName)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer f.Close()
+	if opts.Report != nil {
+		fmt.Fprintf(opts.Report, "columnar: %d rows of %d stations in %d groups\n", f.Rows(), len(f.Names), f.Groups())
+	}
+	res, err := f.Aggregate(opts.Workers)
+	if err != nil {
+		log.Fatal(err)
+	}
+	return common.Finish(res, opts)
+}
+
+// stat is the aggregate of one station, kept in a slice indexed by id.
+type stat struct {
+	min, max   int64
+	sum, count int64
+}
+
+// Aggregate aggregates the rows of f on workers goroutines, 0 picks
+// GOMAXPROCS, which take the row groups in turn. Only the footer is
+// checksummed, so a row group with an id out of the dictionary is an error.
+func (f *File) Aggregate(workers int) (common.Result, error) {
+	if workers <= 0 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+
+	tables := make([][]stat, workers)
+	errs := make([]error, workers)
+	var next atomic.Int64
+	var wg sync.WaitGroup
+	for w := range tables {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			t := make([]stat, len(f.Names))
+			for i := range t {
+				t[i] = stat{min: math.MaxInt64, max: math.MinInt64}
+			}
+			for g := next.Add(1) - 1; g < int64(len(f.groups)); g = next.Add(1) - 1 {
+				ids, temps := f.columns(f.groups[g])
+				temps = temps[:len(ids)]
+				for i, id := range ids {
+					if int(id) >= len(t) {
+						errs[w] = fmt.Errorf("%w: station id %d of %d in the row group at %d", errCorrupt, id, len(t), f.groups[g].off)
+						return
+					}
+					v := int64(temps[i])
+					s := &t[id]
+					s.min = min(s.min, v)
+					s.max = max(s.max, v)
+					s.sum += v
+					s.count++
+				}
+			}
+			tables[w] = t
+		}(w)
+	}
+	wg.Wait()
+	for _, err := range errs {
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	res := make(common.Result, 0, len(f.Names))
+	for id, name := range f.Names {
+		st := common.Station{Name: name, Min: math.MaxInt64, Max: math.MinInt64}
+		for _, t := range tables {
+			s := t[id]
+			st.Min = min(st.Min, s.min)
+			st.Max = max(st.Max, s.max)
+			st.Sum += s.sum
+			st.Count += s.count
+		}
+		if st.Count > 0 {
+			res = append(res, st)
+		}
+	}
+	res.Sort()
+	return res, nil
+}
@@ -0,0 +1,17 @@
+//go:build !purego
+
+package columnar
+
+import "unsafe"
+
+// columns returns the ids and temperatures of g as slices of the mapping,
+// assuming a little-endian machine.
+func (f *File) columns(g group) ([]uint16, []int16) {
+	if g.rows == 0 {
+		return nil, nil
+	}
+	b := f.m.Data[g.off : g.off+g.size()]
+	ids := unsafe.Slice((*uint16)(unsafe.Pointer(&b[0])), g.rows)
+	temps := unsafe.Slice((*int16)(unsafe.Pointer(&b[len(b)/2])), g.rows)
+	return ids, temps
+}
@@ -0,0 +1,17 @@
+//go:build purego
+
+package columnar
+
+import "encoding/binary"
+
+// columns returns the ids and temperatures of g decoded from the mapping.
+func (f *File) columns(g group) ([]uint16, []int16) {
+	b := f.m.Data[g.off : g.off+g.size()]
+	ids := make([]uint16, g.rows)
+	temps := make([]int16, g.rows)
+	for i := range ids {
+		ids[i] = binary.LittleEndian.Uint16(b[2*i:])
+		temps[i] = int16(binary.LittleEndian.Uint16(b[len(b)/2+2*i:]))
+	}
+	return ids, temps
+}
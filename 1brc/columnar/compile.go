@@ -0,0 +1,147 @@
+package columnar
+
+import (
+	"encoding/binary"
+	"fmt"
+	"os"
+	"runtime"
+	"sort"
+	"sync"
+
+	"github.com/draculaas/1brc/common"
+	"github.com/draculaas/1brc/sol4"
+)
+
+// compiler appends the row groups parsed by the workers to the output and
+// assigns the station ids.
+type compiler struct {
+	mu     sync.Mutex
+	out    *os.File
+	off    int64
+	ids    map[string]uint16
+	names  []string
+	groups []group
+	err    error
+}
+
+// Compile converts the measurements file in into the columnar file out,
+// parsing blocks of opts.ChunkSize bytes on opts.Workers goroutines with the
+// parse loop of sol4. Every block becomes a row group.
+func Compile(in, out string, opts common.Options) error {
+	src, err := common.OpenSource("pread", in)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+	f, err := os.Create(out)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if _, err := f.Write(header()); err != nil {
+		return err
+	}
+
+	workers := opts.Workers
+	if workers <= 0 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+	c := &compiler{out: f, off: headerLen, ids: make(map[string]uint16)}
+	// the ids every worker already knows by name, not by the hash of sol4,
+	// which different names share
+	known := make([]map[string]uint16, workers)
+	for i := range known {
+		known[i] = make(map[string]uint16)
+	}
+	bufs := make([][]byte, workers)
+	err = common.ForEachBlockAt(src, opts.ChunkSize, workers, func(w int, off int64, lines []byte) {
+		bufs[w] = c.compile(known[w], bufs[w], off, lines)
+	})
+	if err == nil {
+		err = c.err
+	}
+	if err != nil {
+		return err
+	}
+
+	sort.Slice(c.groups, func(i, j int) bool { return c.groups[i].src < c.groups[j].src })
+	if _, err := f.WriteAt(footer(c.names, c.groups), c.off); err != nil {
+		return err
+	}
+	return f.Close()
+}
+
+// compile parses the lines at off of the measurements file into a row group
+// encoded in buf and appends it.
+func (c *compiler) compile(known map[string]uint16, buf []byte, off int64, lines []byte) []byte {
+	// a line is at least 6 bytes long, "a;0.0\n"
+	rows := 0
+	ids := make([]uint16, 0, len(lines)/6)
+	temps := make([]int16, 0, len(lines)/6)
+	for start := uintptr(0); start < uintptr(len(lines)); rows++ {
+		_, val, nameLen, lineLen := sol4.Parse(lines, start)
+		name := lines[start : start+nameLen]
+		id, ok := known[string(name)]
+		if !ok {
+			var err error
+			if id, err = c.id(string(name)); err != nil {
+				c.fail(err)
+				return buf
+			}
+			known[string(name)] = id
+		}
+		ids = append(ids, id)
+		temps = append(temps, int16(val))
+		start += lineLen
+	}
+
+	g := group{src: off, rows: rows}
+	buf = buf[:0]
+	for _, id := range ids {
+		buf = binary.LittleEndian.AppendUint16(buf, id)
+	}
+	buf = append(buf, make([]byte, g.size()/2-int64(len(buf)))...)
+	for _, t := range temps {
+		buf = binary.LittleEndian.AppendUint16(buf, uint16(t))
+	}
+	buf = append(buf, make([]byte, g.size()-int64(len(buf)))...)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.err != nil {
+		return buf
+	}
+	g.off = c.off
+	if _, err := c.out.WriteAt(buf, g.off); err != nil {
+		c.err = err
+		return buf
+	}
+	c.off += int64(len(buf))
+	c.groups = append(c.groups, g)
+	return buf
+}
+
+// id returns the id of the station name, assigning the next one to a new
+// station.
+func (c *compiler) id(name string) (uint16, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if id, ok := c.ids[name]; ok {
+		return id, nil
+	}
+	if len(c.names) == MaxStations {
+		return 0, fmt.Errorf("more than %d stations", MaxStations)
+	}
+	id := uint16(len(c.names))
+	c.ids[name] = id
+	c.names = append(c.names, name)
+	return id, nil
+}
+
+func (c *compiler) fail(err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.err == nil {
+		c.err = err
+	}
+}
@@ -0,0 +1,180 @@
+// Package columnar compiles measurements files into a columnar binary file,
+// so repeated queries over the same data skip parsing the text, and solves
+// them from it.
+package columnar
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"os"
+
+	"github.com/draculaas/1brc/common"
+)
+
+// A columnar file holds the rows of a measurements file in row groups, one
+// per block of the text compiled, each with a column of station ids and a
+// column of temperatures in tenths, so they are read at memory bandwidth:
+//
+//	header  "1brC", the format version, one byte, and 3 zero bytes
+//	groups  at 8 aligned offsets: rows uint16 station ids, padded to 8
+//	        bytes, then rows int16 temperatures, padded to 8 bytes
+//	footer  uvarint station count, uvarint length and name of every
+//	        station by id; uvarint group count, uvarint offset in the
+//	        measurements file, offset in this file and rows of every group,
+//	        in the order of the measurements file
+//	trailer uint32 footer length, uint32 CRC-32 (Castagnoli) of the
+//	        footer, "1brC"
+//
+// Integers are little endian.
+const (
+	magic      = "1brC"
+	Version    = 1
+	headerLen  = 8
+	trailerLen = 12
+	// MaxStations is the number of stations ids can tell apart.
+	MaxStations = 1 << 16
+)
+
+var castagnoli = crc32.MakeTable(crc32.Castagnoli)
+
+// group is a row group: rows ids at off followed by rows temperatures.
+type group struct {
+	src, off int64
+	rows     int
+}
+
+// size is the number of bytes of the columns of the group.
+func (g group) size() int64 {
+	return 2 * align(2*int64(g.rows))
+}
+
+func align(n int64) int64 {
+	return (n + 7) &^ 7
+}
+
+func header() []byte {
+	return append([]byte(magic), Version, 0, 0, 0)
+}
+
+func footer(names []string, groups []group) []byte {
+	var b []byte
+	b = binary.AppendUvarint(b, uint64(len(names)))
+	for _, name := range names {
+		b = binary.AppendUvarint(b, uint64(len(name)))
+		b = append(b, name...)
+	}
+	b = binary.AppendUvarint(b, uint64(len(groups)))
+	for _, g := range groups {
+		b = binary.AppendUvarint(b, uint64(g.src))
+		b = binary.AppendUvarint(b, uint64(g.off))
+		b = binary.AppendUvarint(b, uint64(g.rows))
+	}
+	b = binary.LittleEndian.AppendUint32(b, uint32(len(b)))
+	b = binary.LittleEndian.AppendUint32(b, crc32.Checksum(b[:len(b)-4], castagnoli))
+	return append(b, magic...)
+}
+
+// File is a columnar file mapped into memory.
+type File struct {
+	// Names are the names of the stations by id.
+	Names  []string
+	m      *common.Mapping
+	groups []group
+}
+
+var errCorrupt = errors.New("corrupt columnar file")
+
+// Open maps the columnar file fileName.
+func Open(fileName string) (*File, error) {
+	info, err := os.Stat(fileName)
+	if err != nil {
+		return nil, err
+	}
+	if info.Size() < headerLen+trailerLen {
+		return nil, fmt.Errorf("%s: not a columnar file", fileName)
+	}
+	f := &File{m: common.Mmap(fileName, 0)}
+	if err := f.decode(); err != nil {
+		f.Close()
+		return nil, fmt.Errorf("%s: %w", fileName, err)
+	}
+	return f, nil
+}
+
+func (f *File) decode() error {
+	data := f.m.Data
+	if string(data[:len(magic)]) != magic || string(data[len(data)-len(magic):]) != magic {
+		return errors.New("not a columnar file")
+	}
+	if v := data[len(magic)]; v != Version {
+		return fmt.Errorf("columnar file of version %d, want %d", v, Version)
+	}
+	trailer := data[len(data)-trailerLen:]
+	n := int64(binary.LittleEndian.Uint32(trailer))
+	if n > int64(len(data)-headerLen-trailerLen) {
+		return errCorrupt
+	}
+	b := data[int64(len(data)-trailerLen)-n : len(data)-trailerLen]
+	if crc32.Checksum(b, castagnoli) != binary.LittleEndian.Uint32(trailer[4:]) {
+		return errors.New("columnar file footer checksum mismatch")
+	}
+	end := int64(len(data)-trailerLen) - n
+
+	next := func() int64 {
+		v, n := binary.Uvarint(b)
+		if n <= 0 || v > 1<<62 {
+			b = nil
+			return -1
+		}
+		b = b[n:]
+		return int64(v)
+	}
+	stations := next()
+	if stations < 0 || stations > MaxStations {
+		return errCorrupt
+	}
+	f.Names = make([]string, stations)
+	for i := range f.Names {
+		l := next()
+		if l < 0 || l > int64(len(b)) {
+			return errCorrupt
+		}
+		f.Names[i], b = string(b[:l]), b[l:]
+	}
+	groups := next()
+	if groups < 0 || groups > int64(len(b)) {
+		return errCorrupt
+	}
+	f.groups = make([]group, groups)
+	for i := range f.groups {
+		g := group{src: next(), off: next()}
+		rows := next()
+		g.rows = int(rows)
+		if g.src < 0 || g.off < headerLen || g.off%8 != 0 || rows < 0 || g.off+g.size() > end {
+			return errCorrupt
+		}
+		f.groups[i] = g
+	}
+	return nil
+}
+
+// Rows returns the number of rows of the file.
+func (f *File) Rows() int64 {
+	var rows int64
+	for _, g := range f.groups {
+		rows += int64(g.rows)
+	}
+	return rows
+}
+
+// Groups returns the number of row groups of the file.
+func (f *File) Groups() int {
+	return len(f.groups)
+}
+
+// Close unmaps the file.
+func (f *File) Close() error {
+	return f.m.Close()
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/draculaas/1brc/columnar"
+	"github.com/draculaas/1brc/common"
+	"github.com/draculaas/1brc/sol1"
+	"github.com/draculaas/1brc/sol4"
+	"github.com/draculaas/1brc/solver"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_TestColumnar(t *testing.T) {
+	for _, name := range find("./test_cases", ".txt") {
+		t.Run(name, func(t *testing.T) {
+			out := filepath.Join(t.TempDir(), "measurements.col")
+			// small blocks, so there are many row groups
+			assert.NoError(t, columnar.Compile(name+".txt", out, common.Options{Workers: 2, ChunkSize: 64}))
+			assert.Equal(t, readFile(name+".out"), columnar.RunWith(out, common.Options{Workers: 2}))
+		})
+	}
+
+	fileName := writeMeasurements(t, randomNames(2_000), 100_000)
+	out := filepath.Join(t.TempDir(), "measurements.col")
+	assert.NoError(t, columnar.Compile(fileName, out, common.Options{Workers: 3, ChunkSize: 4096}))
+	f, err := columnar.Open(out)
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, int64(100_000), f.Rows())
+	assert.Len(t, f.Names, 2_000)
+	assert.Greater(t, f.Groups(), 1)
+	assert.NoError(t, f.Close())
+	run, err := solver.Lookup("columnar")
+	assert.NoError(t, err)
+	assert.Equal(t, sol1.Run(fileName), run(out, common.Options{Workers: 3}))
+
+	// names with the same hash stay apart
+	twins := writeMeasurements(t, sol4Twins, 1_000)
+	assert.NoError(t, columnar.Compile(twins, out, common.Options{Workers: 2, ChunkSize: 4096}))
+	got := columnar.RunWith(out, common.Options{})
+	assert.Equal(t, sol1.Run(twins), got)
+	assert.Contains(t, got, sol4Twins[0]+"=")
+	assert.Contains(t, got, sol4Twins[1]+"=")
+
+	_, err = columnar.Open(fileName)
+	assert.ErrorContains(t, err, "not a columnar file")
+	data, err := os.ReadFile(out)
+	assert.NoError(t, err)
+	// the ids of a row group after the 8 byte header are not checksummed
+	data[8], data[9] = 0xff, 0xff
+	assert.NoError(t, os.WriteFile(out, data, 0644))
+	f, err = columnar.Open(out)
+	if assert.NoError(t, err) {
+		_, err = f.Aggregate(2)
+		assert.ErrorContains(t, err, "station id 65535")
+		assert.NoError(t, f.Close())
+	}
+	data[len(data)-20] ^= 1
+	assert.NoError(t, os.WriteFile(out, data, 0644))
+	_, err = columnar.Open(out)
+	assert.ErrorContains(t, err, "checksum")
+}
+
+// BenchmarkColumnar compares solving a compiled file with parsing the text
+// with sol4.
+func BenchmarkColumnar(b *testing.B) {
+	fileName := writeMeasurements(b, randomNames(10_000), 2_000_000)
+	out := filepath.Join(b.TempDir(), "measurements.col")
+	if err := columnar.Compile(fileName, out, common.Options{}); err != nil {
+		b.Fatal(err)
+	}
+	for _, sol := range []struct {
+		name string
+		run  func() string
+	}{
+		{"sol4", func() string { return sol4.RunWith(fileName, common.Options{}) }},
+		{"columnar", func() string { return columnar.RunWith(out, common.Options{}) }},
+	} {
+		b.Run(sol.name, func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				sol.run()
+			}
+		})
+	}
+}
@@ -0,0 +1,32 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+)
+
+// commands are run as `1brc <command> [args]` instead of solving a file.
+var commands = map[string]func(args []string) error{
+	"validate":      validateCmd,
+	"profile-input": profileInputCmd,
+	"tune":          tuneCmd,
+	"merge":         mergeCmd,
+	"worker":        workerCmd,
+	"coordinate":    coordinateCmd,
+	"serve":         serveCmd,
+	"shell":         shellCmd,
+	"index":         indexCmd,
+	"compile":       compileCmd,
+}
+
+// newFlagSet returns the flag set of a command, exiting on bad flags like
+// the top level flags do.
+func newFlagSet(name, usage string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: %s %s\n", os.Args[0], usage)
+		fs.PrintDefaults()
+	}
+	return fs
+}
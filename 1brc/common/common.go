@@ -1,41 +1,35 @@
 package common
 
 import (
-	"log"
+	"io"
 	"math"
-	"os"
-	"syscall"
 )
 
+// Options tunes how a solution splits up the work. Zero values keep the
+// defaults of the solution.
+type Options struct {
+	// Workers is the number of goroutines parsing the file.
+	Workers int `json:"workers,omitempty"`
+	// ChunkSize is the size in bytes of the blocks read by chunked solutions.
+	ChunkSize int `json:"chunk_size,omitempty"`
+	// Mmap tunes the mapping of solutions working on a mapped file.
+	Mmap MmapFlags `json:"mmap,omitempty"`
+	// Window makes the mapping solutions map the file in line aligned
+	// windows of this many bytes instead of all at once, see
+	// OpenWindowSource.
+	Window int `json:"window,omitempty"`
+	// Dictionary is a file of the station names expected, one per line,
+	// for solutions indexing them with a perfect hash.
+	Dictionary string `json:"dictionary,omitempty"`
+	// Report, when set, receives timing details of the run, such as the
+	// busy time of every worker.
+	Report io.Writer `json:"-"`
+	// Partial, when set, receives the unrounded result of the run in the
+	// partial format, see WritePartial.
+	Partial io.Writer `json:"-"`
+}
+
 //gcassert:inline
 func Round(value float64) float64 {
 	return math.Round(value*10.0) / 10.0
 }
-
-func Mmap(fileName string) []byte {
-	f, err := os.Open(fileName)
-	if err != nil {
-		log.Fatal(err)
-	}
-	return mmapFile(f)
-}
-
-func mmapFile(f *os.File) []byte {
-	fi, err := f.Stat()
-	if err != nil {
-		log.Fatal(err)
-	}
-	sz := fi.Size()
-	if int64(int(sz+4095)) != sz+4095 {
-		log.Fatalf("%s: too large for mmap", f.Name())
-	}
-	n := int(sz)
-	if n == 0 {
-		return nil
-	}
-	data, err := syscall.Mmap(int(f.Fd()), 0, (n+4095)&^4095, syscall.PROT_READ, syscall.MAP_SHARED)
-	if err != nil {
-		log.Fatalf("mmap %s: %v", f.Name(), err)
-	}
-	return data[:n]
-}
@@ -0,0 +1,72 @@
+package common
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+)
+
+// Formats write a result in the output formats of the commands, by name.
+var Formats = map[string]func(w io.Writer, r Result) error{
+	"text":    writeText,
+	"json":    writeJSON,
+	"csv":     writeCSV,
+	"partial": WritePartial,
+}
+
+// FormatNames returns the names of the output formats, sorted.
+func FormatNames() []string {
+	names := make([]string, 0, len(Formats))
+	for name := range Formats {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// WriteFormat writes r to w in the named output format.
+func WriteFormat(w io.Writer, format string, r Result) error {
+	write, ok := Formats[format]
+	if !ok {
+		return fmt.Errorf("unknown output format %q, want one of %v", format, FormatNames())
+	}
+	return write(w, r)
+}
+
+func writeText(w io.Writer, r Result) error {
+	_, err := io.WriteString(w, r.String())
+	return err
+}
+
+func writeJSON(w io.Writer, r Result) error {
+	type station struct {
+		Name  string  `json:"name"`
+		Min   float64 `json:"min"`
+		Mean  float64 `json:"mean"`
+		Max   float64 `json:"max"`
+		Count int64   `json:"count"`
+	}
+	stations := make([]station, len(r))
+	for i, s := range r {
+		stations[i] = station{s.Name, Round(float64(s.Min) / 10.0), s.Mean(), Round(float64(s.Max) / 10.0), s.Count}
+	}
+	return json.NewEncoder(w).Encode(stations)
+}
+
+func writeCSV(w io.Writer, r Result) error {
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"name", "min", "mean", "max", "count"})
+	tenths := func(v float64) string { return strconv.FormatFloat(v, 'f', 1, 64) }
+	for _, s := range r {
+		cw.Write([]string{s.Name,
+			tenths(Round(float64(s.Min) / 10.0)),
+			tenths(s.Mean()),
+			tenths(Round(float64(s.Max) / 10.0)),
+			strconv.FormatInt(s.Count, 10)})
+	}
+	cw.Flush()
+	return cw.Error()
+}
@@ -0,0 +1,237 @@
+package common
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io/fs"
+	"os"
+)
+
+// DefaultIndexStep is the distance between the offsets of an index unless
+// told otherwise. It is the default range size of the solutions, so their
+// ranges can start at every offset.
+const DefaultIndexStep = 1 << 20
+
+// ErrStaleIndex is returned by LoadIndex when the file changed since the
+// index was built.
+var ErrStaleIndex = errors.New("index is stale")
+
+// Index records line aligned offsets of a measurements file, so solvers can
+// split the file at line boundaries without looking for them and without
+// stitching lines split between blocks. It is kept next to the file, see
+// IndexFile, with the size and modification time of the file it describes:
+//
+//	magic    "1bri" and the format version, one byte
+//	header   uvarint size, varint mtime in ns, uvarint step, uvarint count
+//	offsets  uvarint distance to the previous offset, count times
+//	checksum CRC-32 (Castagnoli) of everything before, little endian
+type Index struct {
+	Size    int64
+	ModTime int64
+	Step    int64
+	// Offsets are the starts of the first line at or after every multiple
+	// of Step, from 0, ascending and below Size.
+	Offsets []int64
+}
+
+const (
+	indexMagic   = "1bri"
+	IndexVersion = 1
+)
+
+// IndexFile returns the path of the index of the file fileName.
+func IndexFile(fileName string) string {
+	return fileName + ".idx"
+}
+
+// BuildIndex indexes fileName every step bytes, reading only the bytes
+// around every multiple of step. Zero step picks DefaultIndexStep.
+func BuildIndex(fileName string, step int64) (*Index, error) {
+	if step <= 0 {
+		step = DefaultIndexStep
+	}
+	f, size, err := openSized(fileName, 0)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+
+	ix := &Index{Size: size, ModTime: info.ModTime().UnixNano(), Step: step}
+	if size > 0 {
+		ix.Offsets = append(ix.Offsets, 0)
+	}
+	buf := make([]byte, MaxLineLen+1)
+	for off := step; off < size; off += step {
+		// the line starting at off or the first one after it
+		n, err := f.ReadAt(buf, off-1)
+		if err != nil && n == 0 {
+			return nil, err
+		}
+		i := bytes.IndexByte(buf[:n], '\n')
+		if i < 0 {
+			return nil, fmt.Errorf("%s: no line ends within %d bytes of %d", fileName, n, off-1)
+		}
+		if start := off + int64(i); start < size && start > ix.Offsets[len(ix.Offsets)-1] {
+			ix.Offsets = append(ix.Offsets, start)
+		}
+	}
+	return ix, nil
+}
+
+// Save writes the index to the index file of fileName.
+func (ix *Index) Save(fileName string) error {
+	b := []byte(indexMagic)
+	b = append(b, IndexVersion)
+	b = binary.AppendUvarint(b, uint64(ix.Size))
+	b = binary.AppendVarint(b, ix.ModTime)
+	b = binary.AppendUvarint(b, uint64(ix.Step))
+	b = binary.AppendUvarint(b, uint64(len(ix.Offsets)))
+	prev := int64(0)
+	for _, off := range ix.Offsets {
+		b = binary.AppendUvarint(b, uint64(off-prev))
+		prev = off
+	}
+	b = binary.LittleEndian.AppendUint32(b, crc32.Checksum(b, castagnoli))
+	return os.WriteFile(IndexFile(fileName), b, 0644)
+}
+
+// LoadIndex reads the index file of fileName. It fails with ErrStaleIndex
+// if fileName changed size or modification time since, or if any offset is
+// not at the start of a line.
+func LoadIndex(fileName string) (*Index, error) {
+	b, err := os.ReadFile(IndexFile(fileName))
+	if err != nil {
+		return nil, err
+	}
+	ix, err := decodeIndex(b)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", IndexFile(fileName), err)
+	}
+
+	f, size, err := openSized(fileName, 0)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	if size != ix.Size || info.ModTime().UnixNano() != ix.ModTime {
+		return nil, ErrStaleIndex
+	}
+	var before [1]byte
+	for _, off := range ix.Offsets[min(1, len(ix.Offsets)):] {
+		if _, err := f.ReadAt(before[:], off-1); err != nil {
+			return nil, err
+		}
+		if before[0] != '\n' {
+			return nil, ErrStaleIndex
+		}
+	}
+	return ix, nil
+}
+
+// OpenIndex returns the index of fileName, or nil without a usable one. An
+// index that cannot be used is reported to opts.Report when set.
+func OpenIndex(fileName string, opts Options) *Index {
+	ix, err := LoadIndex(fileName)
+	if err != nil {
+		if opts.Report != nil && !errors.Is(err, fs.ErrNotExist) {
+			fmt.Fprintf(opts.Report, "ignoring the index of %s: %v\n", fileName, err)
+		}
+		return nil
+	}
+	return ix
+}
+
+func decodeIndex(b []byte) (*Index, error) {
+	if len(b) < len(indexMagic)+1+4 || string(b[:len(indexMagic)]) != indexMagic {
+		return nil, errors.New("not an index")
+	}
+	if v := b[len(indexMagic)]; v != IndexVersion {
+		return nil, fmt.Errorf("index of version %d, want %d", v, IndexVersion)
+	}
+	sum := binary.LittleEndian.Uint32(b[len(b)-4:])
+	b = b[:len(b)-4]
+	if crc32.Checksum(b, castagnoli) != sum {
+		return nil, errors.New("index checksum mismatch")
+	}
+
+	r := indexReader{b: b[len(indexMagic)+1:]}
+	ix := &Index{Size: r.uvarint(), ModTime: r.varint(), Step: r.uvarint()}
+	count := r.uvarint()
+	if count > int64(len(r.b)) {
+		return nil, errCorruptIndex
+	}
+	ix.Offsets = make([]int64, count)
+	prev := int64(0)
+	for i := range ix.Offsets {
+		delta := r.uvarint()
+		prev += delta
+		if prev >= ix.Size || (i > 0) != (delta > 0) {
+			return nil, errCorruptIndex
+		}
+		ix.Offsets[i] = prev
+	}
+	if r.err != nil {
+		return nil, r.err
+	}
+	return ix, nil
+}
+
+var errCorruptIndex = errors.New("corrupt index")
+
+// indexReader decodes the varints of an index, keeping the first error.
+type indexReader struct {
+	b   []byte
+	err error
+}
+
+func (r *indexReader) uvarint() int64 {
+	v, n := binary.Uvarint(r.b)
+	if n <= 0 || v > 1<<62 {
+		r.fail()
+		return 0
+	}
+	r.b = r.b[n:]
+	return int64(v)
+}
+
+func (r *indexReader) varint() int64 {
+	v, n := binary.Varint(r.b)
+	if n <= 0 {
+		r.fail()
+		return 0
+	}
+	r.b = r.b[n:]
+	return v
+}
+
+func (r *indexReader) fail() {
+	if r.err == nil {
+		r.err = errCorruptIndex
+	}
+	// leave nothing to decode, so the rest fails as well
+	r.b = nil
+}
+
+// Bounds returns the starts of line aligned ranges followed by the size of
+// the file: every rangeSize/Step-th offset, so the ranges are about rangeSize
+// bytes long, or every offset when the step is rangeSize or more, so they
+// are about Step bytes long.
+func (ix *Index) Bounds(rangeSize int64) []int64 {
+	k := max(int(rangeSize/max(ix.Step, 1)), 1)
+	bounds := make([]int64, 0, len(ix.Offsets)/k+2)
+	for i := 0; i < len(ix.Offsets); i += k {
+		bounds = append(bounds, ix.Offsets[i])
+	}
+	return append(bounds, ix.Size)
+}
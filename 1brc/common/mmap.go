@@ -0,0 +1,120 @@
+package common
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"syscall"
+)
+
+// MmapFlags tune how Mmap maps a file.
+type MmapFlags uint
+
+const (
+	// MmapSequential advises the kernel the mapping is read sequentially,
+	// so it reads ahead aggressively and drops pages behind (MADV_SEQUENTIAL).
+	MmapSequential MmapFlags = 1 << iota
+	// MmapWillNeed asks the kernel to start reading the whole file in the
+	// background (MADV_WILLNEED).
+	MmapWillNeed
+	// MmapPopulate faults in all the pages while mapping (MAP_POPULATE).
+	MmapPopulate
+	// MmapHugePages asks for transparent huge pages (MADV_HUGEPAGE), which
+	// needs a kernel supporting them for file mappings.
+	MmapHugePages
+)
+
+var mmapFlagNames = []struct {
+	flag MmapFlags
+	name string
+}{
+	{MmapSequential, "sequential"},
+	{MmapWillNeed, "willneed"},
+	{MmapPopulate, "populate"},
+	{MmapHugePages, "huge"},
+}
+
+// ParseMmapFlags parses a comma separated list of sequential, willneed,
+// populate and huge, or none, so it reads back what String writes.
+func ParseMmapFlags(s string) (MmapFlags, error) {
+	var flags MmapFlags
+	for _, name := range strings.Split(s, ",") {
+		if name == "" || name == "none" {
+			continue
+		}
+		found := false
+		for _, f := range mmapFlagNames {
+			if f.name == name {
+				flags |= f.flag
+				found = true
+			}
+		}
+		if !found {
+			return 0, fmt.Errorf("unknown mmap flag %q", name)
+		}
+	}
+	return flags, nil
+}
+
+func (f MmapFlags) String() string {
+	var names []string
+	for _, n := range mmapFlagNames {
+		if f&n.flag != 0 {
+			names = append(names, n.name)
+		}
+	}
+	if len(names) == 0 {
+		return "none"
+	}
+	return strings.Join(names, ",")
+}
+
+// Mapping is a read-only mapping of a whole file. Data is only valid until
+// Close, and at least Padding readable bytes follow it even when the file
+// ends on a page boundary.
+type Mapping struct {
+	Data []byte
+	mem  []byte
+}
+
+// Close unmaps the file.
+func (m *Mapping) Close() error {
+	if m.mem == nil {
+		return nil
+	}
+	mem := m.mem
+	m.Data, m.mem = nil, nil
+	return syscall.Munmap(mem)
+}
+
+// Mmap maps the file fileName, exiting on errors.
+func Mmap(fileName string, flags MmapFlags) *Mapping {
+	f, err := os.Open(fileName)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer f.Close()
+	return mmapFile(f, flags)
+}
+
+func mmapFile(f *os.File, flags MmapFlags) *Mapping {
+	fi, err := f.Stat()
+	if err != nil {
+		log.Fatal(err)
+	}
+	sz := fi.Size()
+	if int64(int(sz+4095)) != sz+4095 {
+		log.Fatalf("%s: too large to map at once, map it in windows", f.Name())
+	}
+	n := int(sz)
+	if n == 0 {
+		return &Mapping{}
+	}
+	mem, err := mmapGuarded(int(f.Fd()), 0, n, flags)
+	if err != nil {
+		log.Fatalf("mmap %s: %v", f.Name(), err)
+	}
+	advise(mem[:n], flags)
+	return &Mapping{Data: mem[:n], mem: mem}
+}
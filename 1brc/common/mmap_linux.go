@@ -0,0 +1,48 @@
+package common
+
+import (
+	"os"
+	"syscall"
+	"unsafe"
+)
+
+func mapFlags(flags MmapFlags) int {
+	if flags&MmapPopulate != 0 {
+		return syscall.MAP_POPULATE
+	}
+	return 0
+}
+
+// mmapGuarded maps length bytes of fd at off, followed by at least Padding
+// readable bytes. It reserves an anonymous mapping one page longer than the
+// file range and maps the file over its start, so the guard page reads as
+// zeros instead of faulting when the range ends on a page boundary. The
+// result is unmapped with syscall.Munmap.
+func mmapGuarded(fd int, off int64, length int, flags MmapFlags) ([]byte, error) {
+	page := syscall.Getpagesize()
+	mem, err := syscall.Mmap(-1, 0, (length+page-1)&^(page-1)+page, syscall.PROT_READ, syscall.MAP_PRIVATE|syscall.MAP_ANON)
+	if err != nil {
+		return nil, err
+	}
+	_, _, errno := syscall.Syscall6(syscall.SYS_MMAP, uintptr(unsafe.Pointer(&mem[0])), uintptr(length),
+		syscall.PROT_READ, uintptr(syscall.MAP_SHARED|syscall.MAP_FIXED|mapFlags(flags)), uintptr(fd), uintptr(off))
+	if errno != 0 {
+		syscall.Munmap(mem)
+		return nil, os.NewSyscallError("mmap", errno)
+	}
+	return mem, nil
+}
+
+// advise passes the madvise hints of flags. They are only hints, a kernel
+// not taking them still maps the file.
+func advise(data []byte, flags MmapFlags) {
+	if flags&MmapSequential != 0 {
+		syscall.Madvise(data, syscall.MADV_SEQUENTIAL)
+	}
+	if flags&MmapWillNeed != 0 {
+		syscall.Madvise(data, syscall.MADV_WILLNEED)
+	}
+	if flags&MmapHugePages != 0 {
+		syscall.Madvise(data, syscall.MADV_HUGEPAGE)
+	}
+}
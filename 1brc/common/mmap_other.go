@@ -0,0 +1,39 @@
+//go:build !linux
+
+package common
+
+import (
+	"io"
+	"syscall"
+)
+
+// The mmap flags are Linux only, elsewhere files are mapped plainly.
+
+// mmapGuarded maps length bytes of fd at off, followed by at least Padding
+// readable bytes. When the last page has no room for them, the range is
+// read into an anonymous mapping instead. The result is unmapped with
+// syscall.Munmap.
+func mmapGuarded(fd int, off int64, length int, flags MmapFlags) ([]byte, error) {
+	page := syscall.Getpagesize()
+	if slack := (length+page-1)&^(page-1) - length; slack >= Padding {
+		return syscall.Mmap(fd, off, length, syscall.PROT_READ, syscall.MAP_SHARED)
+	}
+	mem, err := syscall.Mmap(-1, 0, length+Padding, syscall.PROT_READ|syscall.PROT_WRITE, syscall.MAP_PRIVATE|syscall.MAP_ANON)
+	if err != nil {
+		return nil, err
+	}
+	for n := 0; n < length; {
+		m, err := syscall.Pread(fd, mem[n:length], off+int64(n))
+		if err == nil && m == 0 {
+			err = io.ErrUnexpectedEOF
+		}
+		if err != nil {
+			syscall.Munmap(mem)
+			return nil, err
+		}
+		n += m
+	}
+	return mem, nil
+}
+
+func advise(data []byte, flags MmapFlags) {}
@@ -0,0 +1,174 @@
+package common
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+)
+
+// A partial file keeps the unrounded state of a run, so runs over shards of
+// a file can be merged into the exact result of the whole file:
+//
+//	magic    "1brc" and the format version, one byte
+//	count    uvarint, number of stations
+//	station  uvarint name length, name, varint min, max and sum in tenths,
+//	         uvarint count; repeated count times
+//	checksum CRC-32 (Castagnoli) of everything before, little endian
+const (
+	partialMagic   = "1brc"
+	PartialVersion = 1
+)
+
+var castagnoli = crc32.MakeTable(crc32.Castagnoli)
+
+// ErrNotPartial is returned by ReadPartial for data not starting like a
+// partial result.
+var ErrNotPartial = errors.New("not a partial result")
+
+// WritePartial writes the stations of r in the partial format.
+func WritePartial(w io.Writer, r Result) error {
+	crc := crc32.New(castagnoli)
+	bw := bufio.NewWriter(io.MultiWriter(w, crc))
+
+	var buf [binary.MaxVarintLen64]byte
+	uvarint := func(v uint64) { bw.Write(buf[:binary.PutUvarint(buf[:], v)]) }
+	varint := func(v int64) { bw.Write(buf[:binary.PutVarint(buf[:], v)]) }
+
+	bw.WriteString(partialMagic)
+	bw.WriteByte(PartialVersion)
+	uvarint(uint64(len(r)))
+	for _, s := range r {
+		uvarint(uint64(len(s.Name)))
+		bw.WriteString(s.Name)
+		varint(s.Min)
+		varint(s.Max)
+		varint(s.Sum)
+		uvarint(uint64(s.Count))
+	}
+	if err := bw.Flush(); err != nil {
+		return err
+	}
+	_, err := w.Write(binary.LittleEndian.AppendUint32(nil, crc.Sum32()))
+	return err
+}
+
+// ReadPartial reads stations written by WritePartial.
+func ReadPartial(rd io.Reader) (Result, error) {
+	br := bufio.NewReader(rd)
+	tr := &checksummed{r: br}
+
+	var head [len(partialMagic) + 1]byte
+	n, err := io.ReadFull(tr, head[:])
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return nil, fmt.Errorf("partial result: %w", err)
+	}
+	if n < len(head) || string(head[:len(partialMagic)]) != partialMagic {
+		return nil, ErrNotPartial
+	}
+	if v := head[len(partialMagic)]; v != PartialVersion {
+		return nil, fmt.Errorf("partial result of version %d, want %d", v, PartialVersion)
+	}
+
+	count, err := binary.ReadUvarint(tr)
+	if err != nil {
+		return nil, fmt.Errorf("partial result: %w", unexpected(err))
+	}
+	// a corrupt count runs out of stations instead of allocating them all
+	r := make(Result, 0, min(count, 1<<16))
+	for i := uint64(0); i < count; i++ {
+		var s Station
+		nameLen, err := binary.ReadUvarint(tr)
+		if err == nil && nameLen > 1<<16 {
+			err = fmt.Errorf("name of %d bytes", nameLen)
+		}
+		if err == nil {
+			name := make([]byte, nameLen)
+			_, err = io.ReadFull(tr, name)
+			s.Name = string(name)
+		}
+		for _, v := range []*int64{&s.Min, &s.Max, &s.Sum} {
+			if err == nil {
+				*v, err = binary.ReadVarint(tr)
+			}
+		}
+		if err == nil {
+			var c uint64
+			c, err = binary.ReadUvarint(tr)
+			s.Count = int64(c)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("partial result, station %d: %w", i, unexpected(err))
+		}
+		r = append(r, s)
+	}
+
+	want := tr.sum
+	var sum [4]byte
+	if _, err := io.ReadFull(br, sum[:]); err != nil {
+		return nil, fmt.Errorf("partial result checksum: %w", unexpected(err))
+	}
+	if binary.LittleEndian.Uint32(sum[:]) != want {
+		return nil, errors.New("partial result checksum mismatch")
+	}
+	return r, nil
+}
+
+// ReadPartialFile reads the partial file fileName.
+func ReadPartialFile(fileName string) (Result, error) {
+	f, err := os.Open(fileName)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	r, err := ReadPartial(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", fileName, err)
+	}
+	return r, nil
+}
+
+// WritePartialFile writes r to the partial file fileName.
+func WritePartialFile(fileName string, r Result) error {
+	f, err := os.Create(fileName)
+	if err != nil {
+		return err
+	}
+	if err := WritePartial(f, r); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// checksummed reads from r and keeps the checksum of what it read.
+type checksummed struct {
+	r   *bufio.Reader
+	sum uint32
+}
+
+func (c *checksummed) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.sum = crc32.Update(c.sum, castagnoli, p[:n])
+	return n, err
+}
+
+func (c *checksummed) ReadByte() (byte, error) {
+	b, err := c.r.ReadByte()
+	if err == nil {
+		c.sum = crc32.Update(c.sum, castagnoli, []byte{b})
+	}
+	return b, err
+}
+
+// unexpected turns the end of the file in the middle of a partial result
+// into an error telling it is truncated.
+func unexpected(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
@@ -0,0 +1,84 @@
+package common
+
+import (
+	"fmt"
+	"log"
+	"slices"
+	"strings"
+)
+
+// Station is the aggregate of the measurements of one station, in tenths of
+// a degree.
+type Station struct {
+	Name                 string
+	Min, Max, Sum, Count int64
+}
+
+// Mean is the mean temperature of the station in degrees, rounded like the
+// output of the challenge.
+func (s Station) Mean() float64 {
+	return Round(float64(s.Sum) / 10.0 / float64(s.Count))
+}
+
+// Result holds the stations of a run, sorted by name once finished.
+type Result []Station
+
+// String formats the result the way the challenge expects it.
+func (r Result) String() string {
+	var sb strings.Builder
+	sb.WriteString("{")
+	for i, s := range r {
+		if i > 0 {
+			sb.WriteString(", ")
+		}
+		fmt.Fprintf(&sb, "%s=%.1f/%.1f/%.1f", s.Name,
+			Round(float64(s.Min)/10.0),
+			s.Mean(),
+			Round(float64(s.Max)/10.0))
+	}
+	sb.WriteString("}\n")
+	return sb.String()
+}
+
+// Merge combines the stations of several results, for instance of the
+// shards of one file, into one result sorted by name.
+func Merge(results ...Result) Result {
+	index := make(map[string]int)
+	var merged Result
+	for _, r := range results {
+		for _, s := range r {
+			i, ok := index[s.Name]
+			if !ok {
+				index[s.Name] = len(merged)
+				merged = append(merged, s)
+				continue
+			}
+			m := &merged[i]
+			m.Min = min(m.Min, s.Min)
+			m.Max = max(m.Max, s.Max)
+			m.Sum += s.Sum
+			m.Count += s.Count
+		}
+	}
+	merged.Sort()
+	return merged
+}
+
+// Finish sorts the stations of a run, writes them to opts.Partial when set
+// and returns them formatted.
+func Finish(r Result, opts Options) string {
+	r.Sort()
+	if opts.Partial != nil {
+		if err := WritePartial(opts.Partial, r); err != nil {
+			log.Fatalf("Failed to write the partial result %v", err)
+		}
+	}
+	return r.String()
+}
+
+// Sort sorts the stations by name.
+func (r Result) Sort() {
+	slices.SortFunc(r, func(a, b Station) int {
+		return strings.Compare(a.Name, b.Name)
+	})
+}
@@ -0,0 +1,173 @@
+package common
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"runtime"
+	"sync"
+	"time"
+)
+
+// DefaultRangeSize is the size of the ranges Schedule splits the data into
+// unless told otherwise: small enough to leave plenty to steal from a slow
+// worker, large enough to keep the deques out of the profile.
+const DefaultRangeSize = 1 << 20
+
+// WorkerStats is what Schedule measured of one worker.
+type WorkerStats struct {
+	// Busy is the time spent in the callback.
+	Busy time.Duration
+	// Ranges is the number of ranges the worker processed, Stolen how many
+	// of them it took from other workers.
+	Ranges, Stolen int
+}
+
+// span is a line aligned range [start, end) of the data.
+type span struct {
+	start, end int
+}
+
+// deque holds the ranges of one worker. The owner takes them from the
+// front, so it walks its part of the data in order, thieves from the back.
+type deque struct {
+	mu    sync.Mutex
+	spans []span
+}
+
+func (d *deque) pop() (span, bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if len(d.spans) == 0 {
+		return span{}, false
+	}
+	s := d.spans[0]
+	d.spans = d.spans[1:]
+	return s, true
+}
+
+func (d *deque) steal() (span, bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if len(d.spans) == 0 {
+		return span{}, false
+	}
+	s := d.spans[len(d.spans)-1]
+	d.spans = d.spans[:len(d.spans)-1]
+	return s, true
+}
+
+// Schedule splits data into line aligned ranges of about rangeSize bytes,
+// deals consecutive runs of them to workers goroutines and calls
+// fn(worker, start, end) for every range data[start:end]. A worker running
+// out of ranges steals from the back of the deque of another one, so a slow
+// core or a region heavy in page faults does not hold up the others. Zero
+// rangeSize and workers pick DefaultRangeSize and GOMAXPROCS.
+func Schedule(data []byte, rangeSize, workers int, fn func(worker, start, end int)) []WorkerStats {
+	if rangeSize <= 0 {
+		rangeSize = DefaultRangeSize
+	}
+	return schedule(cut(nil, data, 0, len(data), rangeSize), workers, fn)
+}
+
+// cut appends to spans the line aligned ranges of about rangeSize bytes of
+// data[start:end], which ends with a line.
+func cut(spans []span, data []byte, start, end, rangeSize int) []span {
+	for start < end {
+		next := start + rangeSize
+		if next >= end {
+			next = end
+		} else if i := bytes.IndexByte(data[next:end], '\n'); i >= 0 {
+			next += i + 1
+		} else {
+			next = end
+		}
+		spans = append(spans, span{start, next})
+		start = next
+	}
+	return spans
+}
+
+// ScheduleFile is Schedule for data mapped from fileName with ranges of
+// opts.ChunkSize bytes, taking the range boundaries from the index of the
+// file when it has a usable one, see OpenIndex. The ranges of an index with
+// a larger step are cut down to opts.ChunkSize bytes, so stealing stays as
+// fine grained as without an index.
+func ScheduleFile(fileName string, data []byte, opts Options, workers int, fn func(worker, start, end int)) []WorkerStats {
+	rangeSize := opts.ChunkSize
+	if rangeSize <= 0 {
+		rangeSize = DefaultRangeSize
+	}
+	ix := OpenIndex(fileName, opts)
+	if ix == nil || ix.Size != int64(len(data)) {
+		return Schedule(data, rangeSize, workers, fn)
+	}
+	bounds := ix.Bounds(int64(rangeSize))
+	spans := make([]span, 0, len(bounds))
+	for i := 1; i < len(bounds); i++ {
+		start, end := int(bounds[i-1]), int(bounds[i])
+		if end-start > 2*rangeSize {
+			spans = cut(spans, data, start, end, rangeSize)
+		} else {
+			spans = append(spans, span{start, end})
+		}
+	}
+	return schedule(spans, workers, fn)
+}
+
+func schedule(spans []span, workers int, fn func(worker, start, end int)) []WorkerStats {
+	if workers <= 0 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+
+	deques := make([]deque, workers)
+	for w := range deques {
+		deques[w].spans = spans[w*len(spans)/workers : (w+1)*len(spans)/workers]
+	}
+
+	stats := make([]WorkerStats, workers)
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			st := &stats[w]
+			for {
+				s, ok := deques[w].pop()
+				// nothing is added once started, so a round without
+				// anything to steal means all ranges are taken
+				for i := 1; !ok && i < workers; i++ {
+					if s, ok = deques[(w+i)%workers].steal(); ok {
+						st.Stolen++
+					}
+				}
+				if !ok {
+					return
+				}
+				began := time.Now()
+				fn(w, s.start, s.end)
+				st.Busy += time.Since(began)
+				st.Ranges++
+			}
+		}(w)
+	}
+	wg.Wait()
+	return stats
+}
+
+// ReportWorkers writes the busy time of every worker of the solution name
+// to w, as a share of the busiest one.
+func ReportWorkers(w io.Writer, name string, stats []WorkerStats) {
+	var busiest time.Duration
+	for _, st := range stats {
+		busiest = max(busiest, st.Busy)
+	}
+	for i, st := range stats {
+		share := 100.0
+		if busiest > 0 {
+			share = 100 * float64(st.Busy) / float64(busiest)
+		}
+		fmt.Fprintf(w, "%s worker %d: busy %v (%.0f%%), %d ranges, %d stolen\n",
+			name, i, st.Busy.Round(time.Microsecond), share, st.Ranges, st.Stolen)
+	}
+}
@@ -0,0 +1,331 @@
+package common
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"runtime"
+	"sort"
+	"sync"
+)
+
+const (
+	// MaxLineLen is the longest valid line: a 100 byte name, the separator,
+	// "-99.9" and the newline.
+	MaxLineLen = 100 + 1 + 5 + 1
+
+	// DefaultBlockSize is the size of the blocks ForEachBlock hands out
+	// unless told otherwise.
+	DefaultBlockSize = 1 << 20
+
+	// Padding is the number of readable bytes every source guarantees
+	// behind the blocks it hands out, so word loads right after the last
+	// line stay in bounds, also at the end of the file.
+	Padding = 64
+)
+
+// BlockSource reads a file in blocks with one I/O strategy, so parsing can be
+// measured separately from reading.
+type BlockSource interface {
+	// Size is the size of the file in bytes.
+	Size() int64
+	// NewReader returns a reader for use by a single goroutine.
+	NewReader() (BlockReader, error)
+	Close() error
+}
+
+// BlockReader reads blocks of a BlockSource for a single goroutine.
+type BlockReader interface {
+	// ReadBlock returns the n bytes of the file at off, fewer at the end of
+	// the file. The bytes stay valid until the next call.
+	ReadBlock(off int64, n int) ([]byte, error)
+	Close() error
+}
+
+// blockStreamer is implemented by sources keeping many reads in flight on
+// their own, handing the blocks to the workers as they complete.
+type blockStreamer interface {
+	streamBlocks(blockSize, workers int, fn func(worker int, off int64, lines []byte)) error
+}
+
+var sources = map[string]func(fileName string) (BlockSource, error){
+	"mmap":  openMmapSource,
+	"pread": openPreadSource,
+	"read":  openReadSource,
+}
+
+// SourceKinds returns the names of the available block sources, sorted.
+func SourceKinds() []string {
+	kinds := make([]string, 0, len(sources))
+	for kind := range sources {
+		kinds = append(kinds, kind)
+	}
+	sort.Strings(kinds)
+	return kinds
+}
+
+// OpenSource opens fileName for reading with the named strategy.
+func OpenSource(kind, fileName string) (BlockSource, error) {
+	open, ok := sources[kind]
+	if !ok {
+		return nil, fmt.Errorf("unknown block source %q, want one of %v", kind, SourceKinds())
+	}
+	return open(fileName)
+}
+
+// ForEachBlock splits src into blocks of blockSize bytes and calls fn from
+// workers goroutines with the lines starting in every block. Every line is
+// handed out exactly once and in one piece, so no stitching is needed.
+// Zero blockSize and workers pick DefaultBlockSize and GOMAXPROCS.
+func ForEachBlock(src BlockSource, blockSize, workers int, fn func(worker int, lines []byte)) error {
+	return ForEachBlockAt(src, blockSize, workers, func(worker int, _ int64, lines []byte) {
+		fn(worker, lines)
+	})
+}
+
+// ForEachBlockAt is ForEachBlock also passing fn the offset of the lines in
+// the file.
+func ForEachBlockAt(src BlockSource, blockSize, workers int, fn func(worker int, off int64, lines []byte)) error {
+	return ForEachBlockIn(src, 0, src.Size(), blockSize, workers, fn)
+}
+
+// ForEachBlockIn is ForEachBlockAt for the lines starting in the n bytes at
+// off of src, so ranges of a file can be solved apart: the line running over
+// the end of the range is in it, the line running into it is not.
+func ForEachBlockIn(src BlockSource, off, n int64, blockSize, workers int, fn func(worker int, off int64, lines []byte)) error {
+	if blockSize <= 0 {
+		blockSize = DefaultBlockSize
+	}
+	if workers <= 0 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+
+	size := src.Size()
+	end := min(off+n, size)
+	if s, ok := src.(blockStreamer); ok && off == 0 && end == size {
+		return s.streamBlocks(blockSize, workers, fn)
+	}
+
+	offsets := make(chan int64, max(end-off+int64(blockSize)-1, 0)/int64(blockSize))
+	for o := off; o < end; o += int64(blockSize) {
+		offsets <- o
+	}
+	close(offsets)
+
+	var wg sync.WaitGroup
+	errs := make([]error, workers)
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			r, err := src.NewReader()
+			if err != nil {
+				errs[w] = err
+				return
+			}
+			defer r.Close()
+			for o := range offsets {
+				lines, first, err := blockLinesAt(r, o, min(int64(blockSize), end-o), size)
+				if err != nil {
+					errs[w] = err
+					return
+				}
+				if len(lines) > 0 {
+					fn(w, first, lines)
+				}
+			}
+		}(w)
+	}
+	wg.Wait()
+
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// BlockLines reads the lines starting in the n bytes at off of a file of
+// size bytes. It reads one byte before the block to know whether a line
+// starts right at off, and up to MaxLineLen bytes after it to finish the
+// last line.
+func BlockLines(r BlockReader, off, n, size int64) ([]byte, error) {
+	lines, _, err := blockLinesAt(r, off, n, size)
+	return lines, err
+}
+
+// blockLinesAt is BlockLines also returning the offset of the lines in the
+// file.
+func blockLinesAt(r BlockReader, off, n, size int64) ([]byte, int64, error) {
+	start := max(off-1, 0)
+	end := min(off+n+MaxLineLen, size)
+	b, err := r.ReadBlock(start, int(end-start))
+	if err != nil {
+		return nil, 0, err
+	}
+	lines, first := blockLines(b, off, n, start)
+	return lines, first, nil
+}
+
+// blockLines returns the lines starting in the n bytes at off and their
+// offset in the file, given the bytes b of the file starting at start, see
+// BlockLines.
+func blockLines(b []byte, off, n, start int64) ([]byte, int64) {
+	first := 0
+	if off > 0 {
+		i := bytes.IndexByte(b, '\n')
+		if i < 0 || int64(i) >= n {
+			// no line starts in this block
+			return nil, 0
+		}
+		first = i + 1
+	}
+
+	// the last line is the one running over the last byte of the block
+	last := int(off + n - start - 1)
+	if i := bytes.IndexByte(b[last:], '\n'); i >= 0 {
+		return b[first : last+i+1], start + int64(first)
+	}
+	return b[first:], start + int64(first)
+}
+
+// mmapSource maps the whole file once, readers hand out slices of it.
+type mmapSource struct {
+	m *Mapping
+}
+
+func openMmapSource(fileName string) (BlockSource, error) {
+	return &mmapSource{m: Mmap(fileName, 0)}, nil
+}
+
+func (s *mmapSource) Size() int64 { return int64(len(s.m.Data)) }
+
+// NewReader returns a reader over the shared mapping, closing it leaves the
+// mapping to the source.
+func (s *mmapSource) NewReader() (BlockReader, error) { return mmapReader{s.m.Data}, nil }
+
+func (s *mmapSource) Close() error { return s.m.Close() }
+
+type mmapReader struct {
+	data []byte
+}
+
+func (r mmapReader) ReadBlock(off int64, n int) ([]byte, error) {
+	return r.data[off:min(off+int64(n), int64(len(r.data)))], nil
+}
+
+func (r mmapReader) Close() error { return nil }
+
+// preadSource shares one file between all readers, every block is a
+// positioned read into the buffer of the reader.
+type preadSource struct {
+	f    *os.File
+	size int64
+}
+
+func openPreadSource(fileName string) (BlockSource, error) {
+	f, size, err := openSized(fileName, 0)
+	if err != nil {
+		return nil, err
+	}
+	return &preadSource{f: f, size: size}, nil
+}
+
+func (s *preadSource) Size() int64 { return s.size }
+
+func (s *preadSource) NewReader() (BlockReader, error) {
+	return &preadReader{f: s.f}, nil
+}
+
+func (s *preadSource) Close() error { return s.f.Close() }
+
+type preadReader struct {
+	f   *os.File
+	buf []byte
+}
+
+func (r *preadReader) ReadBlock(off int64, n int) ([]byte, error) {
+	r.buf = grow(r.buf, n)
+	m, err := r.f.ReadAt(r.buf[:n], off)
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+	return r.buf[:m], nil
+}
+
+func (r *preadReader) Close() error { return nil }
+
+// readSource opens the file again for every reader, which reads it with
+// plain sequential reads and only seeks when it jumps to another block.
+type readSource struct {
+	fileName string
+	size     int64
+}
+
+func openReadSource(fileName string) (BlockSource, error) {
+	f, size, err := openSized(fileName, 0)
+	if err != nil {
+		return nil, err
+	}
+	f.Close()
+	return &readSource{fileName: fileName, size: size}, nil
+}
+
+func (s *readSource) Size() int64 { return s.size }
+
+func (s *readSource) NewReader() (BlockReader, error) {
+	f, err := os.Open(s.fileName)
+	if err != nil {
+		return nil, err
+	}
+	return &readReader{f: f}, nil
+}
+
+func (s *readSource) Close() error { return nil }
+
+type readReader struct {
+	f   *os.File
+	pos int64
+	buf []byte
+}
+
+func (r *readReader) ReadBlock(off int64, n int) ([]byte, error) {
+	if off != r.pos {
+		if _, err := r.f.Seek(off, io.SeekStart); err != nil {
+			return nil, err
+		}
+	}
+	r.buf = grow(r.buf, n)
+	m, err := io.ReadFull(r.f, r.buf[:n])
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return nil, err
+	}
+	r.pos = off + int64(m)
+	return r.buf[:m], nil
+}
+
+func (r *readReader) Close() error { return r.f.Close() }
+
+// grow returns a buffer of at least n bytes plus padding, reusing buf if it
+// is large enough.
+func grow(buf []byte, n int) []byte {
+	if len(buf) >= n {
+		return buf
+	}
+	return make([]byte, n, n+Padding)
+}
+
+func openSized(fileName string, flag int) (*os.File, int64, error) {
+	f, err := os.OpenFile(fileName, os.O_RDONLY|flag, 0)
+	if err != nil {
+		return nil, 0, err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, 0, err
+	}
+	return f, info.Size(), nil
+}
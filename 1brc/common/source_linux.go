@@ -0,0 +1,85 @@
+package common
+
+import (
+	"io"
+	"os"
+	"syscall"
+	"unsafe"
+)
+
+const directAlign = 4096
+
+func init() {
+	sources["direct"] = openDirectSource
+}
+
+// directSource reads with O_DIRECT, bypassing the page cache. Offsets,
+// lengths and buffers of the reads are aligned to directAlign.
+type directSource struct {
+	f    *os.File
+	size int64
+}
+
+func openDirectSource(fileName string) (BlockSource, error) {
+	f, size, err := openSized(fileName, syscall.O_DIRECT)
+	if err != nil {
+		return nil, err
+	}
+	return &directSource{f: f, size: size}, nil
+}
+
+func (s *directSource) Size() int64 { return s.size }
+
+func (s *directSource) NewReader() (BlockReader, error) {
+	return &directReader{f: s.f}, nil
+}
+
+func (s *directSource) Close() error { return s.f.Close() }
+
+type directReader struct {
+	f   *os.File
+	buf []byte
+}
+
+func (r *directReader) ReadBlock(off int64, n int) ([]byte, error) {
+	start := off &^ (directAlign - 1)
+	end := (off + int64(n) + directAlign - 1) &^ (directAlign - 1)
+	if int64(len(r.buf)) < end-start {
+		r.buf = alignedBuffer(int(end - start))
+	}
+	m, err := r.f.ReadAt(r.buf[:end-start], start)
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+	skip := int(off - start)
+	if m <= skip {
+		return nil, nil
+	}
+	return r.buf[skip:min(m, skip+n)], nil
+}
+
+func (r *directReader) Close() error { return nil }
+
+// alignedBuffer returns a buffer of n bytes plus padding starting at a
+// directAlign boundary.
+func alignedBuffer(n int) []byte {
+	b := make([]byte, n+Padding+directAlign)
+	skip := directAlign - int(uintptr(unsafe.Pointer(&b[0]))&(directAlign-1))
+	return b[skip%directAlign:][:n+Padding]
+}
+
+// Evict drops the pages of fileName from the page cache, so the next read
+// of it is a cold one.
+func Evict(fileName string) error {
+	f, err := os.Open(fileName)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	const fadvDontNeed = 4
+	_, _, errno := syscall.Syscall6(syscall.SYS_FADVISE64, f.Fd(), 0, 0, fadvDontNeed, 0, 0)
+	if errno != 0 {
+		return errno
+	}
+	return nil
+}
@@ -0,0 +1,11 @@
+//go:build !linux
+
+package common
+
+import "errors"
+
+// Evict drops the pages of fileName from the page cache, so the next read
+// of it is a cold one. It is only supported on Linux.
+func Evict(fileName string) error {
+	return errors.New("evicting files from the page cache is not supported")
+}
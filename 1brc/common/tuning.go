@@ -0,0 +1,97 @@
+package common
+
+import (
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// TuningEnv is the environment variable holding the path of the tuning file,
+// overriding the default one in the user config directory. TuningOff turns
+// the tuning off, as tests do to behave the same on every machine.
+const (
+	TuningEnv = "BRC_TUNING"
+	TuningOff = "off"
+)
+
+// Tuning holds the best options found by the tune command on this machine
+// for every solution.
+type Tuning map[string]Options
+
+// TuningFile returns the path of the tuning file of this machine.
+func TuningFile() string {
+	if fileName := os.Getenv(TuningEnv); fileName != "" {
+		return fileName
+	}
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "1brc-tuning.json"
+	}
+	return filepath.Join(dir, "1brc", "tuning.json")
+}
+
+// LoadTuning reads a tuning file. A missing file is an empty tuning.
+func LoadTuning(fileName string) (Tuning, error) {
+	data, err := os.ReadFile(fileName)
+	if errors.Is(err, fs.ErrNotExist) {
+		return Tuning{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	t := Tuning{}
+	if err := json.Unmarshal(data, &t); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// Save writes the tuning to fileName, creating its directory if needed.
+func (t Tuning) Save(fileName string) error {
+	data, err := json.MarshalIndent(t, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(fileName), 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(fileName, append(data, '\n'), 0644)
+}
+
+var (
+	tuningOnce sync.Once
+	tuning     Tuning
+)
+
+// Tuned fills the zero fields of opts with the options the tuning file of
+// this machine holds for the solution name. The file is loaded once, unless
+// $BRC_TUNING is TuningOff.
+func Tuned(name string, opts Options) Options {
+	tuningOnce.Do(func() {
+		if os.Getenv(TuningEnv) == TuningOff {
+			return
+		}
+		var err error
+		if tuning, err = LoadTuning(TuningFile()); err != nil {
+			log.Printf("Ignoring tuning file: %v", err)
+		}
+	})
+	t := tuning[name]
+	if opts.Workers == 0 {
+		opts.Workers = t.Workers
+	}
+	if opts.ChunkSize == 0 {
+		opts.ChunkSize = t.ChunkSize
+	}
+	if opts.Mmap == 0 {
+		opts.Mmap = t.Mmap
+	}
+	if opts.Window == 0 {
+		opts.Window = t.Window
+	}
+	return opts
+}
@@ -0,0 +1,391 @@
+package common
+
+import (
+	"os"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"unsafe"
+)
+
+// io_uring constants from include/uapi/linux/io_uring.h.
+const (
+	sysIoUringSetup = 425
+	sysIoUringEnter = 426
+
+	ioringOpRead         = 22
+	ioringEnterGetevents = 1 << 0
+	ioringFeatSingleMmap = 1 << 0
+
+	ioringOffSqRing = 0
+	ioringOffCqRing = 0x8000000
+	ioringOffSqes   = 0x10000000
+
+	// uringEntries is the size of the submission queue and the number of
+	// reads kept in flight.
+	uringEntries = 32
+)
+
+func init() {
+	sources["uring"] = openUringSource
+}
+
+type uringParams struct {
+	sqEntries    uint32
+	cqEntries    uint32
+	flags        uint32
+	sqThreadCPU  uint32
+	sqThreadIdle uint32
+	features     uint32
+	wqFd         uint32
+	resv         [3]uint32
+	sqOff        struct {
+		head, tail, ringMask, ringEntries, flags, dropped, array, resv1 uint32
+		userAddr                                                        uint64
+	}
+	cqOff struct {
+		head, tail, ringMask, ringEntries, overflow, cqes, flags, resv1 uint32
+		userAddr                                                        uint64
+	}
+}
+
+type uringSQE struct {
+	opcode      uint8
+	flags       uint8
+	ioprio      uint16
+	fd          int32
+	off         uint64
+	addr        uint64
+	len         uint32
+	rwFlags     uint32
+	userData    uint64
+	bufIndex    uint16
+	personality uint16
+	spliceFdIn  int32
+	addr3       uint64
+	_           uint64
+}
+
+type uringCQE struct {
+	userData uint64
+	res      int32
+	flags    uint32
+}
+
+// uring is a minimal io_uring driven through raw system calls. It is used
+// by a single goroutine.
+type uring struct {
+	fd      int
+	rings   [][]byte
+	entries uint32
+
+	sqHead, sqTail *uint32
+	sqMask         uint32
+	sqArray        []uint32
+	sqes           []uringSQE
+	queued         uint32
+
+	cqHead, cqTail *uint32
+	cqMask         uint32
+	cqes           []uringCQE
+}
+
+func newUring(entries uint32) (*uring, error) {
+	var p uringParams
+	fd, _, errno := syscall.Syscall(sysIoUringSetup, uintptr(entries), uintptr(unsafe.Pointer(&p)), 0)
+	if errno != 0 {
+		return nil, os.NewSyscallError("io_uring_setup", errno)
+	}
+	r := &uring{fd: int(fd), entries: p.sqEntries}
+
+	sqSize := int(p.sqOff.array + p.sqEntries*4)
+	cqSize := int(p.cqOff.cqes + p.cqEntries*uint32(unsafe.Sizeof(uringCQE{})))
+	if p.features&ioringFeatSingleMmap != 0 {
+		sqSize = max(sqSize, cqSize)
+	}
+	sq, err := r.mmap(ioringOffSqRing, sqSize)
+	if err != nil {
+		return nil, err
+	}
+	cq := sq
+	if p.features&ioringFeatSingleMmap == 0 {
+		if cq, err = r.mmap(ioringOffCqRing, cqSize); err != nil {
+			return nil, err
+		}
+	}
+	sqes, err := r.mmap(ioringOffSqes, int(p.sqEntries)*int(unsafe.Sizeof(uringSQE{})))
+	if err != nil {
+		return nil, err
+	}
+
+	r.sqHead = (*uint32)(unsafe.Pointer(&sq[p.sqOff.head]))
+	r.sqTail = (*uint32)(unsafe.Pointer(&sq[p.sqOff.tail]))
+	r.sqMask = *(*uint32)(unsafe.Pointer(&sq[p.sqOff.ringMask]))
+	r.sqArray = unsafe.Slice((*uint32)(unsafe.Pointer(&sq[p.sqOff.array])), p.sqEntries)
+	r.sqes = unsafe.Slice((*uringSQE)(unsafe.Pointer(&sqes[0])), p.sqEntries)
+
+	r.cqHead = (*uint32)(unsafe.Pointer(&cq[p.cqOff.head]))
+	r.cqTail = (*uint32)(unsafe.Pointer(&cq[p.cqOff.tail]))
+	r.cqMask = *(*uint32)(unsafe.Pointer(&cq[p.cqOff.ringMask]))
+	r.cqes = unsafe.Slice((*uringCQE)(unsafe.Pointer(&cq[p.cqOff.cqes])), p.cqEntries)
+	return r, nil
+}
+
+func (r *uring) mmap(offset int64, size int) ([]byte, error) {
+	b, err := syscall.Mmap(r.fd, offset, size, syscall.PROT_READ|syscall.PROT_WRITE, syscall.MAP_SHARED|syscall.MAP_POPULATE)
+	if err != nil {
+		r.close()
+		return nil, os.NewSyscallError("mmap", err)
+	}
+	r.rings = append(r.rings, b)
+	return b, nil
+}
+
+func (r *uring) close() {
+	for _, b := range r.rings {
+		syscall.Munmap(b)
+	}
+	r.rings = nil
+	syscall.Close(r.fd)
+}
+
+// queueRead queues a read of buf from fd at off, submitted by the next
+// call to wait.
+func (r *uring) queueRead(fd int, buf []byte, off int64, userData uint64) {
+	tail := *r.sqTail
+	idx := tail & r.sqMask
+	r.sqes[idx] = uringSQE{
+		opcode:   ioringOpRead,
+		fd:       int32(fd),
+		off:      uint64(off),
+		addr:     uint64(uintptr(unsafe.Pointer(&buf[0]))),
+		len:      uint32(len(buf)),
+		userData: userData,
+	}
+	r.sqArray[idx] = idx
+	atomic.StoreUint32(r.sqTail, tail+1)
+	r.queued++
+}
+
+// wait submits the queued reads and waits for at least one completion.
+func (r *uring) wait() error {
+	for {
+		n, _, errno := syscall.Syscall6(sysIoUringEnter, uintptr(r.fd), uintptr(r.queued), 1, ioringEnterGetevents, 0, 0)
+		if errno == syscall.EINTR {
+			continue
+		}
+		if errno != 0 {
+			return os.NewSyscallError("io_uring_enter", errno)
+		}
+		r.queued -= uint32(n)
+		return nil
+	}
+}
+
+// reap calls fn for every completed read.
+func (r *uring) reap(fn func(userData uint64, res int32)) {
+	head := *r.cqHead
+	for tail := atomic.LoadUint32(r.cqTail); head != tail; head++ {
+		cqe := r.cqes[head&r.cqMask]
+		fn(cqe.userData, cqe.res)
+	}
+	atomic.StoreUint32(r.cqHead, head)
+}
+
+// uringSource reads blocks with io_uring, keeping uringEntries reads in
+// flight on a single goroutine that hands the completed blocks to the
+// workers. Readers from NewReader use pread on the same file.
+type uringSource struct {
+	*preadSource
+	mu   sync.Mutex
+	ring *uring
+	// broken is set when reads could not be waited for, which may still
+	// complete into their buffers, so the ring is not used again
+	broken error
+}
+
+// openUringSource falls back to a pread source if io_uring is missing,
+// disabled or blocked by seccomp.
+func openUringSource(fileName string) (BlockSource, error) {
+	src, err := openPreadSource(fileName)
+	if err != nil {
+		return nil, err
+	}
+	ps := src.(*preadSource)
+	if UringSupported() != nil {
+		return ps, nil
+	}
+	ring, err := newUring(uringEntries)
+	if err != nil {
+		return ps, nil
+	}
+	return &uringSource{preadSource: ps, ring: ring}, nil
+}
+
+var (
+	uringOnce sync.Once
+	uringErr  error
+)
+
+// UringSupported returns why io_uring cannot be used to read files, or nil
+// if it can. The uring block source falls back to pread in that case.
+func UringSupported() error {
+	uringOnce.Do(func() {
+		ring, err := newUring(1)
+		if err != nil {
+			uringErr = err
+			return
+		}
+		defer ring.close()
+
+		// kernels before 5.6 know io_uring but not IORING_OP_READ
+		f, err := os.Open("/proc/self/stat")
+		if err != nil {
+			uringErr = err
+			return
+		}
+		defer f.Close()
+		buf := make([]byte, 1)
+		ring.queueRead(int(f.Fd()), buf, 0, 0)
+		if uringErr = ring.wait(); uringErr != nil {
+			return
+		}
+		ring.reap(func(_ uint64, res int32) {
+			if res < 0 {
+				uringErr = os.NewSyscallError("io_uring read", syscall.Errno(-res))
+			}
+		})
+	})
+	return uringErr
+}
+
+func (s *uringSource) Close() error {
+	s.ring.close()
+	return s.preadSource.Close()
+}
+
+// uringRead is a block being read into one of the buffers.
+type uringRead struct {
+	off, n int64 // the block
+	start  int64 // first byte read, one before the block
+	want   int   // bytes to read from start
+	got    int   // bytes read so far
+}
+
+func (s *uringSource) streamBlocks(blockSize, workers int, fn func(worker int, off int64, lines []byte)) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.broken != nil {
+		return s.broken
+	}
+
+	size := s.size
+	blocks := int((size + int64(blockSize) - 1) / int64(blockSize))
+	depth := min(int(s.ring.entries), max(blocks, 1))
+
+	// buffers live outside of the Go heap, the kernel writes to them
+	// while no Go pointer to the reads is around
+	bufSize := blockSize + 1 + MaxLineLen + Padding
+	mem, err := syscall.Mmap(-1, 0, depth*bufSize, syscall.PROT_READ|syscall.PROT_WRITE, syscall.MAP_PRIVATE|syscall.MAP_ANON)
+	if err != nil {
+		return os.NewSyscallError("mmap", err)
+	}
+	fd := int(s.f.Fd())
+	next, inflight := 0, 0
+	defer func() {
+		// a read still in flight would write into unmapped memory, or
+		// into whatever is mapped there next, so its buffers are leaked
+		if inflight == 0 {
+			syscall.Munmap(mem)
+		} else {
+			s.broken = err
+		}
+	}()
+	buffer := func(i int) []byte {
+		return mem[i*bufSize : (i+1)*bufSize-Padding]
+	}
+
+	reads := make([]uringRead, depth)
+	free := make(chan int, depth)
+	for i := 0; i < depth; i++ {
+		free <- i
+	}
+	ready := make(chan int, depth)
+
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := range ready {
+				rd := reads[i]
+				if lines, first := blockLines(buffer(i)[:rd.got], rd.off, rd.n, rd.start); len(lines) > 0 {
+					fn(w, first, lines)
+				}
+				free <- i
+			}
+		}(w)
+	}
+
+	for (next < blocks && err == nil) || inflight > 0 {
+		// keep the queue full while there are free buffers
+	queue:
+		for next < blocks && err == nil {
+			var i int
+			if inflight == 0 {
+				i = <-free
+			} else {
+				select {
+				case i = <-free:
+				default:
+					break queue
+				}
+			}
+			off := int64(next) * int64(blockSize)
+			start := max(off-1, 0)
+			reads[i] = uringRead{
+				off:   off,
+				n:     min(int64(blockSize), size-off),
+				start: start,
+				want:  int(min(off+int64(blockSize)+MaxLineLen, size) - start),
+			}
+			s.ring.queueRead(fd, buffer(i)[:reads[i].want], start, uint64(i))
+			next++
+			inflight++
+		}
+
+		if err = s.ring.wait(); err != nil {
+			// the reads in flight still write into mem, wait for them
+			// before it is unmapped
+			for inflight > 0 && s.ring.wait() == nil {
+				s.ring.reap(func(uint64, int32) { inflight-- })
+			}
+			break
+		}
+		s.ring.reap(func(userData uint64, res int32) {
+			i := int(userData)
+			rd := &reads[i]
+			switch {
+			case res < 0:
+				err = os.NewSyscallError("io_uring read", syscall.Errno(-res))
+			case res > 0 && rd.got+int(res) < rd.want:
+				// short read, queue the rest
+				rd.got += int(res)
+				s.ring.queueRead(fd, buffer(i)[rd.got:rd.want], rd.start+int64(rd.got), userData)
+				return
+			default:
+				rd.got += int(res)
+			}
+			inflight--
+			if res < 0 {
+				free <- i
+			} else {
+				ready <- i
+			}
+		})
+	}
+
+	close(ready)
+	wg.Wait()
+	return err
+}
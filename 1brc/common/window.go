@@ -0,0 +1,77 @@
+package common
+
+import (
+	"os"
+	"syscall"
+)
+
+func init() {
+	sources["window"] = func(fileName string) (BlockSource, error) {
+		return OpenWindowSource(fileName, 0)
+	}
+}
+
+// windowSource maps the file block by block instead of all at once. Every
+// reader keeps only its current window mapped, so the mapped memory stays
+// below workers windows whatever the size of the file.
+type windowSource struct {
+	f     *os.File
+	size  int64
+	flags MmapFlags
+}
+
+// OpenWindowSource opens fileName for reading through a sliding window
+// mapping, every window mapped with flags. The window is the block size
+// handed to ForEachBlock, and BlockLines aligns it to lines.
+func OpenWindowSource(fileName string, flags MmapFlags) (BlockSource, error) {
+	f, size, err := openSized(fileName, 0)
+	if err != nil {
+		return nil, err
+	}
+	return &windowSource{f: f, size: size, flags: flags}, nil
+}
+
+func (s *windowSource) Size() int64 { return s.size }
+
+func (s *windowSource) NewReader() (BlockReader, error) {
+	return &windowReader{s: s}, nil
+}
+
+func (s *windowSource) Close() error { return s.f.Close() }
+
+type windowReader struct {
+	s   *windowSource
+	mem []byte
+}
+
+// ReadBlock unmaps the previous window and maps the pages holding the n
+// bytes at off, followed by Padding readable bytes.
+func (r *windowReader) ReadBlock(off int64, n int) ([]byte, error) {
+	if err := r.Close(); err != nil {
+		return nil, err
+	}
+	end := min(off+int64(n), r.s.size)
+	if end <= off {
+		return nil, nil
+	}
+	start := off &^ int64(syscall.Getpagesize()-1)
+	// map Padding bytes more when the file has them, so the window does not
+	// end on an unmapped page in the middle of the file
+	mapped := min(end+Padding, r.s.size)
+	mem, err := mmapGuarded(int(r.s.f.Fd()), start, int(mapped-start), r.s.flags)
+	if err != nil {
+		return nil, err
+	}
+	advise(mem[:mapped-start], r.s.flags)
+	r.mem = mem
+	return mem[off-start : end-start], nil
+}
+
+func (r *windowReader) Close() error {
+	if r.mem == nil {
+		return nil
+	}
+	mem := r.mem
+	r.mem = nil
+	return syscall.Munmap(mem)
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/draculaas/1brc/columnar"
+	"github.com/draculaas/1brc/common"
+)
+
+func compileCmd(args []string) error {
+	fs := newFlagSet("compile", "compile [-o out] [-workers n] [-chunk-size n] <file>")
+	out := fs.String("o", "", "columnar file to write, <file>.col by default")
+	workers := fs.Int("workers", 0, "number of goroutines parsing the file, 0 uses GOMAXPROCS")
+	chunkSize := fs.Int("chunk-size", 0, "size in bytes of the blocks parsed, one row group each, 0 keeps the default")
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		fs.Usage()
+		os.Exit(2)
+	}
+	in := fs.Arg(0)
+	if *out == "" {
+		*out = in + ".col"
+	}
+
+	if err := columnar.Compile(in, *out, common.Options{Workers: *workers, ChunkSize: *chunkSize}); err != nil {
+		return err
+	}
+	f, err := columnar.Open(*out)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	inInfo, err := os.Stat(in)
+	if err != nil {
+		return err
+	}
+	outInfo, err := os.Stat(*out)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("%s: %d rows of %d stations in %d groups, %d bytes from %d\n",
+		*out, f.Rows(), len(f.Names), f.Groups(), outInfo.Size(), inInfo.Size())
+	return nil
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"fmt"
+	"math/rand"
+	"sort"
+)
+
+// picker chooses the station of the next generated row.
+type picker interface {
+	next() int
+}
+
+// newPicker returns the picker for the named key distribution over n stations.
+func newPicker(dist string, rng *rand.Rand, stations []WeatherStation) (picker, error) {
+	n := len(stations)
+	switch dist {
+	case "uniform":
+		return &uniformPicker{rng: rng, n: n}, nil
+	case "zipf":
+		if *zipfS <= 1 {
+			return nil, fmt.Errorf("zipf exponent must be > 1, got %v", *zipfS)
+		}
+		return &zipfPicker{
+			zipf: rand.NewZipf(rng, *zipfS, 1, uint64(n-1)),
+			perm: rng.Perm(n),
+		}, nil
+	case "hot":
+		if *hotKeys < 1 || *hotKeys > n {
+			return nil, fmt.Errorf("number of hot keys must be in 1..%d, got %d", n, *hotKeys)
+		}
+		if *hotShare < 0 || *hotShare > 1 {
+			return nil, fmt.Errorf("share of the hot keys must be in [0,1], got %v", *hotShare)
+		}
+		return &hotPicker{rng: rng, n: n, share: *hotShare, hot: rng.Perm(n)[:*hotKeys]}, nil
+	case "runs":
+		if *runLength < 1 {
+			return nil, fmt.Errorf("run length must be positive, got %d", *runLength)
+		}
+		order := make([]int, n)
+		for i := range order {
+			order[i] = i
+		}
+		sort.Slice(order, func(i, j int) bool {
+			return stations[order[i]].id < stations[order[j]].id
+		})
+		return &runPicker{order: order, length: *runLength, pos: -1}, nil
+	}
+	return nil, fmt.Errorf("unknown distribution %q", dist)
+}
+
+// uniformPicker picks every station with the same probability.
+type uniformPicker struct {
+	rng *rand.Rand
+	n   int
+}
+
+func (p *uniformPicker) next() int {
+	return p.rng.Intn(p.n)
+}
+
+// zipfPicker picks the station of rank k with probability proportional to
+// 1/(1+k)^s. Ranks are assigned to stations in random order.
+type zipfPicker struct {
+	zipf *rand.Zipf
+	perm []int
+}
+
+func (p *zipfPicker) next() int {
+	return p.perm[p.zipf.Uint64()]
+}
+
+// hotPicker sends share of the rows to a few hot stations and spreads the
+// rest uniformly over all of them.
+type hotPicker struct {
+	rng   *rand.Rand
+	n     int
+	share float64
+	hot   []int
+}
+
+func (p *hotPicker) next() int {
+	if p.rng.Float64() < p.share {
+		return p.hot[p.rng.Intn(len(p.hot))]
+	}
+	return p.rng.Intn(p.n)
+}
+
+// runPicker emits runs of length rows of the same station, walking the
+// stations in sorted order.
+type runPicker struct {
+	order  []int
+	length int
+	pos    int
+	left   int
+}
+
+func (p *runPicker) next() int {
+	if p.left == 0 {
+		p.pos = (p.pos + 1) % len(p.order)
+		p.left = p.length
+	}
+	p.left--
+	return p.order[p.pos]
+}
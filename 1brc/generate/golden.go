@@ -0,0 +1,73 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/draculaas/1brc/common"
+)
+
+// result is the exact aggregate of one station, kept in tenths of a degree
+// like the solutions do, so no floating point error creeps in while writing.
+type result struct {
+	min, max, sum, count int64
+}
+
+// golden accumulates the expected output while the measurements are written.
+type golden struct {
+	results []result
+}
+
+func newGolden(n int) *golden {
+	return &golden{results: make([]result, n)}
+}
+
+func (g *golden) add(station int, val int64) {
+	r := &g.results[station]
+	if r.count == 0 {
+		*r = result{min: val, max: val, sum: val, count: 1}
+		return
+	}
+	r.min = min(r.min, val)
+	r.max = max(r.max, val)
+	r.sum += val
+	r.count++
+}
+
+// write stores the expected solution output for the given stations in fileName,
+// rounding exactly like the solutions do.
+func (g *golden) write(fileName string, stations []WeatherStation) error {
+	idx := make([]int, 0, len(stations))
+	for i := range stations {
+		if g.results[i].count > 0 {
+			idx = append(idx, i)
+		}
+	}
+	sort.Slice(idx, func(i, j int) bool {
+		return stations[idx[i]].id < stations[idx[j]].id
+	})
+
+	var sb strings.Builder
+	sb.WriteString("{")
+	for i, s := range idx {
+		if i > 0 {
+			sb.WriteString(", ")
+		}
+		r := g.results[s]
+		sb.WriteString(fmt.Sprintf("%s=%.1f/%.1f/%.1f", stations[s].id,
+			common.Round(float64(r.min)/10.0),
+			common.Round(float64(r.sum)/10.0/float64(r.count)),
+			common.Round(float64(r.max)/10.0)))
+	}
+	sb.WriteString("}\n")
+
+	return os.WriteFile(fileName, []byte(sb.String()), 0644)
+}
+
+// goldenName returns the name of the expected output file stored next to the
+// measurements file, e.g. measurements-10.txt -> measurements-10.out.
+func goldenName(fileName string) string {
+	return strings.TrimSuffix(fileName, ".txt") + ".out"
+}
@@ -2,12 +2,17 @@ package main
 
 import (
 	"bufio"
+	"flag"
 	"fmt"
 	"log"
+	"math"
 	"math/rand"
 	"os"
 	"strconv"
 	"time"
+
+	"github.com/draculaas/1brc/collide"
+	"github.com/draculaas/1brc/validate"
 )
 
 type WeatherStation struct {
@@ -15,8 +20,11 @@ type WeatherStation struct {
 	meanTemp float64
 }
 
-func (w WeatherStation) measurement(rng *rand.Rand) float64 {
-	return rng.NormFloat64()*10 + w.meanTemp
+// measurement returns a random temperature in tenths of a degree, clamped to
+// the -99.9..99.9 range allowed by the rules.
+func (w WeatherStation) measurement(rng *rand.Rand) int64 {
+	val := int64(math.Round((rng.NormFloat64()*10 + w.meanTemp) * 10))
+	return min(max(val, -999), 999)
 }
 
 var stations = []WeatherStation{
@@ -435,41 +443,113 @@ var stations = []WeatherStation{
 	{"Zürich", 9.3},
 }
 
+// withNames returns stations with the given names, reusing the mean
+// temperatures of the built-in stations.
+func withNames(names []string) []WeatherStation {
+	res := make([]WeatherStation, len(names))
+	for i, name := range names {
+		res[i] = WeatherStation{id: name, meanTemp: stations[i%len(stations)].meanTemp}
+	}
+	return res
+}
+
+var (
+	out       = flag.String("out", "measurements.txt", "path of the generated measurements `file`")
+	seed      = flag.Int64("seed", 0, "random seed, 0 picks one from the current time")
+	dist      = flag.String("dist", "uniform", "station key distribution: uniform, zipf, hot or runs")
+	zipfS     = flag.Float64("zipf-s", 1.1, "exponent of the zipf distribution, must be > 1")
+	hotKeys   = flag.Int("hot", 10, "number of hot stations of the hot distribution")
+	hotShare  = flag.Float64("hot-share", 0.9, "share of rows going to the hot stations")
+	runLength = flag.Int("run", 100_000, "number of consecutive rows of one station in the runs distribution")
+	collision = flag.String("collide", "", "generate station names colliding under the hash of sol3 or sol4")
+	numNames  = flag.Int("stations", 10_000, "number of generated station names, up to 10000")
+	nameMin   = flag.Int("name-min", 1, "minimum length in bytes of synthetic station names")
+	nameMax   = flag.Int("name-max", 0, "maximum length in bytes of synthetic station names, up to 100; 0 uses the built-in stations")
+	utf8Share [5]float64
+)
+
+func init() {
+	flag.Float64Var(&utf8Share[2], "utf8-2", 0, "share of 2-byte code points in synthetic station names")
+	flag.Float64Var(&utf8Share[3], "utf8-3", 0, "share of 3-byte code points in synthetic station names")
+	flag.Float64Var(&utf8Share[4], "utf8-4", 0, "share of 4-byte code points in synthetic station names")
+}
+
 func main() {
-	if len(os.Args) != 2 {
+	flag.Parse()
+	if flag.NArg() != 1 {
 		log.Fatalf("Missing args")
 		return
 	}
-	start := time.Now()
-	f, err := os.Create("measurements.txt")
+	size, err := strconv.ParseInt(flag.Arg(0), 10, 64)
 	if err != nil {
-		panic(err)
+		log.Fatal(err)
 	}
-	defer func() {
-		err := f.Close()
+	if *seed == 0 {
+		*seed = time.Now().UnixNano()
+	}
+	if err := generate(*out, size, rand.New(rand.NewSource(*seed))); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// generate writes size measurements of the stations and distribution picked
+// by the flags to fileName, and their expected output next to it, see
+// goldenName.
+func generate(fileName string, size int64, rng *rand.Rand) error {
+	start := time.Now()
+	if *numNames < 1 || *numNames > validate.MaxStations {
+		return fmt.Errorf("number of stations must be in 1..%d, got %d", validate.MaxStations, *numNames)
+	}
+	stations := stations
+	if *collision != "" {
+		names, err := collide.ByName(*collision, *numNames)
+		if err != nil {
+			return err
+		}
+		stations = withNames(names)
+	} else if *nameMax > 0 {
+		shape := nameShape{minLen: *nameMin, maxLen: *nameMax, shares: utf8Share}
+		if err := shape.validate(); err != nil {
+			return err
+		}
+		names, err := shape.names(rng, *numNames)
 		if err != nil {
-			panic(err)
+			return err
 		}
-	}()
+		stations = withNames(names)
+	}
 
-	size, err := strconv.ParseInt(os.Args[1], 10, 64)
+	keys, err := newPicker(*dist, rng, stations)
 	if err != nil {
-		panic(err)
+		return err
 	}
 
+	// created once every flag is known to be valid, so a bad one leaves no
+	// file behind
+	f, err := os.Create(fileName)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	expected := newGolden(len(stations))
 	w := bufio.NewWriterSize(f, 512<<10 /* 512 KB */)
-	rng := rand.New(rand.NewSource(time.Now().UnixNano()))
 	for i := int64(0); i < size; i++ {
 		if i > 0 && i%50_000_000 == 0 {
 			fmt.Printf("Wrote %d measurements in %d ms\n", i, time.Now().Sub(start).Milliseconds())
 		}
-		s := stations[rng.Intn(len(stations))]
-		_, err := fmt.Fprintf(w, "%s;%.1f\n", s.id, s.measurement(rng))
-		if err != nil {
-			panic(err)
+		idx := keys.next()
+		s := stations[idx]
+		val := s.measurement(rng)
+		expected.add(idx, val)
+		if _, err := fmt.Fprintf(w, "%s;%.1f\n", s.id, float64(val)/10.0); err != nil {
+			return err
 		}
 	}
-	if err = w.Flush(); err != nil {
-		panic(err)
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
 	}
+	return expected.write(goldenName(fileName), stations)
 }
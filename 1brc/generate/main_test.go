@@ -0,0 +1,84 @@
+package main
+
+import (
+	"math/rand"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/draculaas/1brc/sol1"
+	"github.com/stretchr/testify/assert"
+)
+
+// Test_TestGenerate checks the expected output written next to the
+// measurements is what sol1 computes, for every distribution, and that a
+// seed gives the same file every time.
+func Test_TestGenerate(t *testing.T) {
+	defer func(d string, max int) { *dist, *nameMax = d, max }(*dist, *nameMax)
+	for _, d := range []string{"uniform", "zipf", "hot", "runs"} {
+		for _, synthetic := range []int{0, maxNameLen} {
+			*dist, *nameMax = d, synthetic
+			fileName := filepath.Join(t.TempDir(), "measurements.txt")
+			assert.NoError(t, generate(fileName, 20_000, rand.New(rand.NewSource(1))))
+			want, err := os.ReadFile(goldenName(fileName))
+			assert.NoError(t, err)
+			assert.Equal(t, string(want), sol1.Run(fileName), "%s %d", d, synthetic)
+
+			again := filepath.Join(t.TempDir(), "measurements.txt")
+			assert.NoError(t, generate(again, 20_000, rand.New(rand.NewSource(1))))
+			first, err := os.ReadFile(fileName)
+			assert.NoError(t, err)
+			second, err := os.ReadFile(again)
+			assert.NoError(t, err)
+			assert.Equal(t, first, second, "%s %d", d, synthetic)
+		}
+	}
+}
+
+// Test_TestGenerateFlags checks bad flags fail before the file is created.
+func Test_TestGenerateFlags(t *testing.T) {
+	defer func(n, max int, d string, share float64) {
+		*numNames, *nameMax, *dist, *hotShare = n, max, d, share
+	}(*numNames, *nameMax, *dist, *hotShare)
+	for _, set := range []func(){
+		func() { *numNames, *nameMax = 0, 20 },
+		func() { *numNames, *nameMax = -1, 20 },
+		func() { *numNames = 10_001 },
+		func() { *dist = "bogus" },
+		func() { *dist, *hotShare = "hot", 1.5 },
+	} {
+		*numNames, *nameMax, *dist, *hotShare = 10_000, 0, "uniform", 0.9
+		set()
+		fileName := filepath.Join(t.TempDir(), "measurements.txt")
+		assert.Error(t, generate(fileName, 10, rand.New(rand.NewSource(1))))
+		assert.NoFileExists(t, fileName)
+	}
+}
+
+func Test_TestNames(t *testing.T) {
+	shape := nameShape{minLen: 1, maxLen: maxNameLen, shares: [5]float64{2: 0.2, 3: 0.2, 4: 0.2}}
+	assert.NoError(t, shape.validate())
+	names, err := shape.names(rand.New(rand.NewSource(1)), 10_000)
+	assert.NoError(t, err)
+	assert.Len(t, names, 10_000)
+	seen := make(map[string]bool, len(names))
+	for _, name := range names {
+		assert.True(t, utf8.ValidString(name), name)
+		assert.GreaterOrEqual(t, len(name), 1)
+		assert.LessOrEqual(t, len(name), maxNameLen)
+		assert.False(t, strings.ContainsAny(name, ";\n"), name)
+		assert.False(t, seen[name], name)
+		seen[name] = true
+	}
+
+	for _, bad := range []nameShape{
+		{minLen: 0, maxLen: 10},
+		{minLen: 1, maxLen: maxNameLen + 1},
+		{minLen: 5, maxLen: 4},
+		{minLen: 1, maxLen: 10, shares: [5]float64{2: 0.6, 3: 0.6}},
+	} {
+		assert.Error(t, bad.validate(), "%+v", bad)
+	}
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"fmt"
+	"math/rand"
+	"unicode/utf8"
+)
+
+// Station names may be up to 100 bytes of UTF-8.
+const maxNameLen = 100
+
+// nameShape describes synthetic station names: their length in bytes is
+// uniform in [minLen, maxLen] and every code point is 2, 3 or 4 bytes wide
+// with the given shares, the rest being ASCII.
+type nameShape struct {
+	minLen, maxLen int
+	shares         [5]float64 // indexed by the width of the code point
+}
+
+func (s nameShape) validate() error {
+	if s.minLen < 1 || s.maxLen > maxNameLen || s.minLen > s.maxLen {
+		return fmt.Errorf("name length must be within 1..%d bytes, got %d..%d", maxNameLen, s.minLen, s.maxLen)
+	}
+	total := 0.0
+	for _, share := range s.shares[2:] {
+		if share < 0 {
+			return fmt.Errorf("negative utf-8 share %v", share)
+		}
+		total += share
+	}
+	if total > 1 {
+		return fmt.Errorf("utf-8 shares add up to %v, more than 1", total)
+	}
+	return nil
+}
+
+// Code points of every width used in synthetic names. None of them is ';' or
+// '\n', and the ranges hold no surrogates, so every name is valid UTF-8.
+var (
+	ascii      = []byte("abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789 '-.")
+	codePoints = [5][2]rune{
+		2: {0x00C0, 0x017F},   // Latin-1 supplement and Latin extended-A
+		3: {0x4E00, 0x9FFF},   // CJK unified ideographs
+		4: {0x1F300, 0x1F5FF}, // Miscellaneous symbols and pictographs
+	}
+)
+
+// names returns n distinct station names of the given shape.
+func (s nameShape) names(rng *rand.Rand, n int) ([]string, error) {
+	seen := make(map[string]bool, n)
+	names := make([]string, 0, n)
+	for tries := 0; len(names) < n; tries++ {
+		if tries > 100*n {
+			return nil, fmt.Errorf("could only generate %d of %d distinct names of %d..%d bytes", len(names), n, s.minLen, s.maxLen)
+		}
+		name := s.name(rng)
+		if !seen[name] {
+			seen[name] = true
+			names = append(names, name)
+		}
+	}
+	return names, nil
+}
+
+func (s nameShape) name(rng *rand.Rand) string {
+	size := s.minLen + rng.Intn(s.maxLen-s.minLen+1)
+	b := make([]byte, 0, size)
+	for len(b) < size {
+		width := s.width(rng)
+		if left := size - len(b); width > left {
+			width = left
+		}
+		if width == 1 {
+			b = append(b, ascii[rng.Intn(len(ascii))])
+			continue
+		}
+		r := codePoints[width]
+		b = utf8.AppendRune(b, r[0]+rune(rng.Intn(int(r[1]-r[0]+1))))
+	}
+	return string(b)
+}
+
+// width picks the width in bytes of the next code point.
+func (s nameShape) width(rng *rand.Rand) int {
+	x := rng.Float64()
+	for w := 2; w <= 4; w++ {
+		if x < s.shares[w] {
+			return w
+		}
+		x -= s.shares[w]
+	}
+	return 1
+}
@@ -0,0 +1,30 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/draculaas/1brc/common"
+)
+
+func indexCmd(args []string) error {
+	fs := newFlagSet("index", "index [-step MB] <file>...")
+	step := fs.Int64("step", common.DefaultIndexStep>>20, "distance between the offsets of the index in MB")
+	fs.Parse(args)
+	if fs.NArg() == 0 {
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	for _, fileName := range fs.Args() {
+		ix, err := common.BuildIndex(fileName, *step<<20)
+		if err != nil {
+			return err
+		}
+		if err := ix.Save(fileName); err != nil {
+			return err
+		}
+		fmt.Printf("%s: %d offsets\n", common.IndexFile(fileName), len(ix.Offsets))
+	}
+	return nil
+}
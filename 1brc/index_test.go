@@ -0,0 +1,94 @@
+package main
+
+import (
+	"os"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/draculaas/1brc/common"
+	"github.com/draculaas/1brc/sol1"
+	"github.com/draculaas/1brc/sol2"
+	"github.com/draculaas/1brc/sol3"
+	"github.com/draculaas/1brc/sol4"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_TestIndex(t *testing.T) {
+	fileName := writeMeasurements(t, randomNames(1_000), 50_000)
+	data, err := os.ReadFile(fileName)
+	assert.NoError(t, err)
+
+	ix, err := common.BuildIndex(fileName, 4096)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(0), ix.Offsets[0])
+	for i, off := range ix.Offsets[1:] {
+		assert.Equal(t, byte('\n'), data[off-1])
+		assert.Less(t, ix.Offsets[i], off)
+	}
+	assert.InDelta(t, len(data)/4096, len(ix.Offsets), 1)
+	assert.Len(t, ix.Bounds(4096), len(ix.Offsets)+1)
+	assert.Len(t, ix.Bounds(1), len(ix.Offsets)+1)
+	assert.Len(t, ix.Bounds(3*4096), (len(ix.Offsets)+2)/3+1)
+	assert.NoError(t, ix.Save(fileName))
+	loaded, err := common.LoadIndex(fileName)
+	assert.NoError(t, err)
+	assert.Equal(t, ix, loaded)
+
+	want := sol1.Run(fileName)
+	for name, run := range map[string]func(string, common.Options) string{
+		"sol2": sol2.RunWith,
+		"sol3": sol3.RunWith,
+		"sol4": sol4.RunWith,
+	} {
+		var report strings.Builder
+		got := run(fileName, common.Options{Workers: 3, ChunkSize: 4096, Report: &report})
+		assert.Equal(t, want, got, name)
+		assert.NotContains(t, report.String(), "ignoring", name)
+	}
+
+	// with an index, ranges are cut at its offsets; the callbacks run on
+	// both workers
+	var ranges atomic.Int64
+	common.ScheduleFile(fileName, data, common.Options{ChunkSize: 4096}, 2, func(_, start, end int) {
+		assert.Contains(t, ix.Offsets, int64(start))
+		ranges.Add(1)
+	})
+	assert.Equal(t, int64(len(ix.Offsets)), ranges.Load())
+	// and further when they are too long for the range size
+	ranges.Store(0)
+	common.ScheduleFile(fileName, data, common.Options{ChunkSize: 1024}, 2, func(_, start, end int) {
+		assert.LessOrEqual(t, end-start, 1024+common.MaxLineLen)
+		ranges.Add(1)
+	})
+	assert.InDelta(t, len(data)/1024, ranges.Load(), float64(len(ix.Offsets)))
+
+	// the same size and time but moved lines
+	info, err := os.Stat(fileName)
+	assert.NoError(t, err)
+	rotated := append(append([]byte{}, data[1:]...), data[0])
+	assert.NoError(t, os.WriteFile(fileName, rotated, 0644))
+	assert.NoError(t, os.Chtimes(fileName, info.ModTime(), info.ModTime()))
+	_, err = common.LoadIndex(fileName)
+	assert.ErrorIs(t, err, common.ErrStaleIndex)
+
+	// a newer file
+	assert.NoError(t, os.WriteFile(fileName, data, 0644))
+	_, err = common.LoadIndex(fileName)
+	assert.ErrorIs(t, err, common.ErrStaleIndex)
+	for name, run := range map[string]func(string, common.Options) string{
+		"sol3": sol3.RunWith,
+		"sol4": sol4.RunWith,
+	} {
+		var report strings.Builder
+		assert.Equal(t, want, run(fileName, common.Options{Workers: 3, ChunkSize: 4096, Report: &report}), name)
+		assert.Contains(t, report.String(), "ignoring the index", name)
+	}
+
+	idx, err := os.ReadFile(common.IndexFile(fileName))
+	assert.NoError(t, err)
+	idx[len(idx)/2] ^= 1
+	assert.NoError(t, os.WriteFile(common.IndexFile(fileName), idx, 0644))
+	_, err = common.LoadIndex(fileName)
+	assert.ErrorContains(t, err, "checksum")
+}
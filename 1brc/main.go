@@ -3,7 +3,8 @@ package main
 import (
 	"flag"
 	"fmt"
-	"github.com/draculaas/1brc/sol4"
+	"github.com/draculaas/1brc/common"
+	"github.com/draculaas/1brc/solver"
 	"log"
 	"os"
 	"runtime"
@@ -16,10 +17,31 @@ var name = flag.String("name", "", "path to the file")
 var cpuprofile = flag.String("cpuprofile", "", "write cpu profile to `file`")
 var memprofile = flag.String("memprofile", "", "write memory profile to `file`")
 var executionprofile = flag.String("execprofile", "", "write trace execution to `file`")
+var sol = flag.String("sol", "sol4", "name of the solution to run, auto picks one from a profile of the file")
+var workers = flag.Int("workers", 0, "number of workers, 0 keeps the default of the solution")
+var source = flag.String("source", "", "read the file through this block source: mmap, pread, read, direct or uring")
+var mmapFlags = flag.String("mmap", "", "comma separated mmap tuning of the mapping solutions: sequential, willneed, populate, huge or none")
+var window = flag.Int("window", 0, "map the file in windows of this many bytes in sol2 and sol3, 0 maps it at once")
+var report = flag.Bool("report", false, "write timing details of the run, such as the busy time of every worker, to stderr")
+var dict = flag.String("dict", "", "file of the expected station names, one per line, looked up with a perfect hash by sol4")
+var aggregate = flag.String("agg", "", "print this aggregate per station computed by the loop of -sol, sol3 or sol4, instead: min-mean-max, count-above:<degrees>, first-last or distinct")
+var chunkSize = flag.Int("chunk-size", 0, "size in bytes of the blocks read by chunked solutions, 0 keeps the default")
+var partial = flag.String("partial", "", "also write the unrounded result to this partial `file`, see the merge command")
 
 func main() {
 	flag.Parse()
 
+	if flag.NArg() > 0 {
+		cmd, ok := commands[flag.Arg(0)]
+		if !ok {
+			log.Fatalf("Unknown command %q", flag.Arg(0))
+		}
+		if err := cmd(flag.Args()[1:]); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
 	start := time.Now()
 
 	if *executionprofile != "" {
@@ -51,7 +73,62 @@ func main() {
 		log.Fatalf("Filename param is missing")
 	}
 
-	sol4.Run("./data/" + *name)
+	mmap, err := common.ParseMmapFlags(*mmapFlags)
+	if err != nil {
+		log.Fatal(err)
+	}
+	opts := common.Options{Workers: *workers, ChunkSize: *chunkSize, Mmap: mmap, Window: *window, Dictionary: *dict}
+	if *report {
+		opts.Report = os.Stderr
+	}
+	if *partial != "" {
+		if *aggregate != "" {
+			log.Fatalf("-partial keeps min, max, sum and count, it cannot be used with -agg")
+		}
+		f, err := os.Create(*partial)
+		if err != nil {
+			log.Fatalf("Failed to create the partial result %v", err)
+		}
+		defer func() {
+			if err := f.Close(); err != nil {
+				log.Fatalf("Failed to write the partial result %v", err)
+			}
+		}()
+		opts.Partial = f
+	}
+	if *aggregate != "" {
+		run, err := solver.LookupAggregate(*sol, *aggregate)
+		if err != nil {
+			log.Fatal(err)
+		}
+		kind := *source
+		if kind == "" {
+			kind = "mmap"
+		}
+		src, err := common.OpenSource(kind, "./data/"+*name)
+		if err != nil {
+			log.Fatal(err)
+		}
+		fmt.Print(run(src, opts))
+		src.Close()
+	} else if *source != "" {
+		run, err := solver.LookupSource(*sol)
+		if err != nil {
+			log.Fatal(err)
+		}
+		src, err := common.OpenSource(*source, "./data/"+*name)
+		if err != nil {
+			log.Fatal(err)
+		}
+		run(src, opts)
+		src.Close()
+	} else {
+		run, err := solver.Lookup(*sol)
+		if err != nil {
+			log.Fatal(err)
+		}
+		run("./data/"+*name, opts)
+	}
 
 	fmt.Println(time.Now().Sub(start))
 
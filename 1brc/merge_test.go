@@ -0,0 +1,32 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/draculaas/1brc/collide"
+	"github.com/draculaas/1brc/common"
+	"github.com/draculaas/1brc/sol1"
+	"github.com/draculaas/1brc/sol4"
+	"github.com/stretchr/testify/assert"
+)
+
+// Test_TestMerge merges sol4 tables on more shards than the test machine has
+// cores, with stations spread over the table and with long probe runs
+// crossing shard boundaries, and many lines split between chunks.
+func Test_TestMerge(t *testing.T) {
+	clustered := collide.Sol4(2_000)
+	for name, names := range map[string][]string{
+		"spread":    randomNames(10_000),
+		"clustered": clustered,
+	} {
+		fileName := writeMeasurements(t, names, 50_000)
+		want := sol1.Run(fileName)
+		for _, workers := range []int{1, 3, 16} {
+			var report strings.Builder
+			got := sol4.RunWith(fileName, common.Options{Workers: workers, ChunkSize: 4096, Report: &report})
+			assert.Equal(t, want, got, "%s on %d workers", name, workers)
+			assert.Contains(t, report.String(), "merge")
+		}
+	}
+}
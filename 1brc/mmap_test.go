@@ -0,0 +1,91 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/draculaas/1brc/common"
+	"github.com/draculaas/1brc/sol2"
+	"github.com/draculaas/1brc/sol3"
+	"github.com/draculaas/1brc/solver"
+	"github.com/stretchr/testify/assert"
+)
+
+var mmapFlagSets = []common.MmapFlags{
+	0,
+	common.MmapSequential,
+	common.MmapWillNeed,
+	common.MmapPopulate,
+	common.MmapHugePages,
+	common.MmapSequential | common.MmapWillNeed | common.MmapPopulate | common.MmapHugePages,
+}
+
+func Test_TestMmapFlags(t *testing.T) {
+	for _, flags := range mmapFlagSets {
+		parsed, err := common.ParseMmapFlags(flags.String())
+		assert.NoError(t, err)
+		assert.Equal(t, flags, parsed)
+	}
+	assert.Equal(t, "none", common.MmapFlags(0).String())
+	_, err := common.ParseMmapFlags("sequential,bogus")
+	assert.Error(t, err)
+
+	for _, flags := range mmapFlagSets {
+		for _, name := range find("./test_cases", ".txt") {
+			got := sol2.RunWith(name+".txt", common.Options{Mmap: flags})
+			assert.Equal(t, readFile(name+".out"), got, "%s %s", flags, name)
+		}
+	}
+}
+
+// BenchmarkMmap measures sol2 and sol3 with every mmap flag on warm and
+// cold page cache.
+func BenchmarkMmap(b *testing.B) {
+	fileName := writeMeasurements(b, randomNames(10_000), 2_000_000)
+
+	sols := []struct {
+		name string
+		run  solver.Func
+	}{
+		{"sol2", sol2.RunWith},
+		{"sol3", sol3.RunWith},
+	}
+
+	for _, flags := range mmapFlagSets {
+		for _, cache := range []string{"warm", "cold"} {
+			for _, s := range sols {
+				b.Run(flags.String()+"/"+cache+"/"+s.name, func(b *testing.B) {
+					for i := 0; i < b.N; i++ {
+						if cache == "cold" {
+							b.StopTimer()
+							if err := common.Evict(fileName); err != nil {
+								b.Skip(err)
+							}
+							b.StartTimer()
+						}
+						s.run(fileName, common.Options{Mmap: flags})
+					}
+				})
+			}
+		}
+	}
+}
+
+func Test_TestMmapWindow(t *testing.T) {
+	fileName := writeMeasurements(t, randomNames(500), 50_000)
+	want := sol2.RunWith(fileName, common.Options{})
+
+	// windows of a page and smaller than a page, so lines straddle every
+	// window boundary
+	for _, window := range []int{4096, 1000} {
+		for _, s := range []struct {
+			name string
+			run  solver.Func
+		}{{"sol2", sol2.RunWith}, {"sol3", sol3.RunWith}} {
+			var report strings.Builder
+			got := s.run(fileName, common.Options{Workers: 2, Window: window, Mmap: common.MmapSequential, Report: &report})
+			assert.Equal(t, want, got, "%s window %d", s.name, window)
+			assert.Contains(t, report.String(), s.name+" worker 1: busy", "%s window %d", s.name, window)
+		}
+	}
+}
@@ -0,0 +1,131 @@
+// Package mph builds minimal perfect hashes of 64-bit keys by hash and
+// displace: keys are grouped into small buckets, and every bucket gets the
+// first seed sending all its keys to slots no other key took.
+package mph
+
+import (
+	"errors"
+	"fmt"
+	"math/bits"
+	"sort"
+)
+
+const (
+	// bucketLoad is the mean number of keys per bucket: more makes the
+	// table smaller and the build slower.
+	bucketLoad = 4
+	// maxSeed bounds the search for the seed of a bucket.
+	maxSeed = 1 << 24
+)
+
+// Table maps each of the n keys it was built from to a distinct slot in
+// [0, n), and any other key to some slot in that range, so callers keep the
+// key of every slot to tell them apart.
+type Table struct {
+	seeds []uint32
+	n     uint64
+}
+
+// Build returns the table of keys, which must be distinct.
+func Build(keys []uint64) (*Table, error) {
+	n := uint64(len(keys))
+	if n == 0 {
+		return &Table{}, nil
+	}
+	seen := make(map[uint64]bool, n)
+	for _, k := range keys {
+		if seen[k] {
+			return nil, fmt.Errorf("duplicate key %#x", k)
+		}
+		seen[k] = true
+	}
+
+	m := (n + bucketLoad - 1) / bucketLoad
+	buckets := make([][]uint64, m)
+	for _, k := range keys {
+		b := reduce(mix(k), m)
+		buckets[b] = append(buckets[b], k)
+	}
+	// place the big buckets while most slots are free
+	order := make([]int, m)
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(i, j int) bool {
+		return len(buckets[order[i]]) > len(buckets[order[j]])
+	})
+
+	t := &Table{seeds: make([]uint32, m), n: n}
+	taken := make([]bool, n)
+	slots := make([]uint64, 0, 16)
+	for _, b := range order {
+		if len(buckets[b]) == 0 {
+			break
+		}
+		seed := uint32(0)
+		for ; seed < maxSeed; seed++ {
+			slots = slots[:0]
+			for _, k := range buckets[b] {
+				s := t.slot(k, seed)
+				if taken[s] || contains(slots, s) {
+					break
+				}
+				slots = append(slots, s)
+			}
+			if len(slots) == len(buckets[b]) {
+				break
+			}
+		}
+		if seed == maxSeed {
+			return nil, errors.New("no seed places all the keys of a bucket")
+		}
+		for _, s := range slots {
+			taken[s] = true
+		}
+		t.seeds[b] = seed
+	}
+	return t, nil
+}
+
+// Len returns the number of keys and slots of the table.
+func (t *Table) Len() int {
+	return int(t.n)
+}
+
+// Index returns the slot of key.
+func (t *Table) Index(key uint64) uint64 {
+	if t.n == 0 {
+		return 0
+	}
+	return t.slot(key, t.seeds[reduce(mix(key), uint64(len(t.seeds)))])
+}
+
+func (t *Table) slot(key uint64, seed uint32) uint64 {
+	return reduce(mix(key^uint64(seed)*0x9E3779B97F4A7C15), t.n)
+}
+
+// mix is the finalizer of MurmurHash3, spreading every input bit over the
+// whole word.
+func mix(x uint64) uint64 {
+	x ^= x >> 33
+	x *= 0xff51afd7ed558ccd
+	x ^= x >> 33
+	x *= 0xc4ceb9fe1a85ec53
+	x ^= x >> 33
+	return x
+}
+
+// reduce maps x to [0, n) with a multiplication instead of a division.
+func reduce(x, n uint64) uint64 {
+	hi, _ := bits.Mul64(x, n)
+	return hi
+}
+
+func contains(slots []uint64, s uint64) bool {
+	for _, x := range slots {
+		if x == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/draculaas/1brc/common"
+	"github.com/draculaas/1brc/mph"
+	"github.com/draculaas/1brc/sol1"
+	"github.com/draculaas/1brc/sol4"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_TestPerfectHash(t *testing.T) {
+	f, err := os.Open("../data/weather_stations.csv")
+	assert.NoError(t, err)
+	defer f.Close()
+	seen := make(map[uint64]bool)
+	var keys []uint64
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		if name, _, _ := strings.Cut(sc.Text(), ";"); !strings.HasPrefix(name, "#") {
+			if k := sol4.HashName([]byte(name)); !seen[k] {
+				seen[k] = true
+				keys = append(keys, k)
+			}
+		}
+	}
+
+	table, err := mph.Build(keys)
+	assert.NoError(t, err)
+	assert.Equal(t, len(keys), table.Len())
+	slots := make([]bool, len(keys))
+	for _, k := range keys {
+		i := table.Index(k)
+		assert.False(t, slots[i], "slot %d taken twice", i)
+		slots[i] = true
+	}
+
+	_, err = mph.Build([]uint64{1, 2, 1})
+	assert.Error(t, err)
+}
+
+func Test_TestDictionary(t *testing.T) {
+	names := randomNames(400)
+	// the last 50 names are unknown
+	known := names[:350]
+	dictName := filepath.Join(t.TempDir(), "stations.csv")
+	dict := "# comment\n" + strings.Join(known, ";1.0\n") + "\n"
+	assert.NoError(t, os.WriteFile(dictName, []byte(dict), 0644))
+
+	for name, stations := range map[string][]string{
+		"known":   known,
+		"unknown": names,
+	} {
+		fileName := writeMeasurements(t, stations, 20_000)
+		want := sol1.Run(fileName)
+		var report strings.Builder
+		opts := common.Options{Workers: 3, ChunkSize: 4096, Dictionary: dictName, Report: &report}
+		assert.Equal(t, want, sol4.RunWith(fileName, opts), name)
+
+		src, err := common.OpenSource("mmap", fileName)
+		assert.NoError(t, err)
+		assert.Equal(t, want, sol4.RunSource(src, opts), name)
+		src.Close()
+
+		if name == "unknown" {
+			assert.Contains(t, report.String(), "350 found, 50 unknown")
+		}
+	}
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/draculaas/1brc/common"
+	"github.com/draculaas/1brc/sol1"
+	"github.com/draculaas/1brc/sol2"
+	"github.com/draculaas/1brc/sol3"
+	"github.com/draculaas/1brc/sol4"
+	"github.com/draculaas/1brc/sol5"
+	"github.com/draculaas/1brc/sol6"
+	"github.com/stretchr/testify/assert"
+)
+
+// writeSized writes a measurements file of exactly size bytes ending with
+// the shortest possible line, so word loads on the last line run past the
+// end of the file.
+func writeSized(tb testing.TB, size int) string {
+	tb.Helper()
+	const last = "z;1.0\n"
+	temps := []string{"12.3", "-4.5", "99.9", "-0.1"}
+	var sb strings.Builder
+	for i := 0; sb.Len() < size-len(last); i++ {
+		// a name finishing the file exactly, unless it leaves room for
+		// another line of at least 7 bytes
+		rem := size - len(last) - sb.Len()
+		n := rem - 6
+		if n > 40 {
+			n = 20 + i%20
+		}
+		sb.WriteString(strings.Repeat(string(rune('a'+i%25)), n))
+		sb.WriteString(";" + temps[i%len(temps)] + "\n")
+	}
+	sb.WriteString(last)
+	fileName := filepath.Join(tb.TempDir(), fmt.Sprintf("measurements-%d.txt", size))
+	if err := os.WriteFile(fileName, []byte(sb.String()), 0644); err != nil {
+		tb.Fatal(err)
+	}
+	return fileName
+}
+
+// Test_TestPadding runs every solution doing word loads, through every way
+// it reads, on files ending on and around page boundaries.
+func Test_TestPadding(t *testing.T) {
+	page := os.Getpagesize()
+	solutions := map[string]func(t *testing.T, fileName string) string{
+		"sol3": func(t *testing.T, fileName string) string {
+			return sol3.RunWith(fileName, common.Options{Workers: 2})
+		},
+		"sol3/window": func(t *testing.T, fileName string) string {
+			return sol3.RunWith(fileName, common.Options{Workers: 2, Window: page})
+		},
+		"sol2/window": func(t *testing.T, fileName string) string {
+			return sol2.RunWith(fileName, common.Options{Workers: 2, Window: page})
+		},
+		"sol4": func(t *testing.T, fileName string) string {
+			return sol4.RunWith(fileName, common.Options{Workers: 2, ChunkSize: page})
+		},
+	}
+	for _, kind := range common.SourceKinds() {
+		for name, run := range map[string]func(common.BlockSource, common.Options) string{
+			"sol3": sol3.RunSource,
+			"sol4": sol4.RunSource,
+			"sol5": sol5.RunSource,
+			"sol6": sol6.RunSource,
+		} {
+			// t is the subtest, so an unavailable source skips only it
+			solutions[name+"/"+kind] = func(t *testing.T, fileName string) string {
+				src, err := common.OpenSource(kind, fileName)
+				if err != nil {
+					t.Skipf("%s source unavailable: %v", kind, err)
+				}
+				defer src.Close()
+				return run(src, common.Options{Workers: 2, ChunkSize: page})
+			}
+		}
+	}
+
+	for _, size := range []int{page, page - 1, page + 1, 2*page - common.Padding, 3 * page} {
+		fileName := writeSized(t, size)
+		info, err := os.Stat(fileName)
+		assert.NoError(t, err)
+		assert.Equal(t, int64(size), info.Size())
+		want := sol1.Run(fileName)
+		for name, run := range solutions {
+			t.Run(fmt.Sprintf("%s/%d", name, size), func(t *testing.T) {
+				assert.Equal(t, want, run(t, fileName))
+			})
+		}
+	}
+}
@@ -0,0 +1,35 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/draculaas/1brc/common"
+)
+
+func mergeCmd(args []string) error {
+	fs := newFlagSet("merge", "merge [-o merged] <partial file>...")
+	out := fs.String("o", "", "also write the merged result to this partial `file`, to merge it again later")
+	fs.Parse(args)
+	if fs.NArg() == 0 {
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	results := make([]common.Result, 0, fs.NArg())
+	for _, fileName := range fs.Args() {
+		r, err := common.ReadPartialFile(fileName)
+		if err != nil {
+			return err
+		}
+		results = append(results, r)
+	}
+	merged := common.Merge(results...)
+	if *out != "" {
+		if err := common.WritePartialFile(*out, merged); err != nil {
+			return err
+		}
+	}
+	fmt.Print(merged)
+	return nil
+}
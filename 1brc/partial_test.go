@@ -0,0 +1,88 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/draculaas/1brc/common"
+	"github.com/draculaas/1brc/solver"
+	"github.com/stretchr/testify/assert"
+)
+
+// splitLines writes the lines of fileName to n shard files of about the same
+// size.
+func splitLines(t *testing.T, fileName string, n int) []string {
+	data, err := os.ReadFile(fileName)
+	assert.NoError(t, err)
+	shards := make([]string, n)
+	for i := range shards {
+		end := len(data)
+		if i < n-1 {
+			end = len(data) / (n - i)
+			end += bytes.IndexByte(data[end:], '\n') + 1
+		}
+		shards[i] = filepath.Join(t.TempDir(), "shard.txt")
+		assert.NoError(t, os.WriteFile(shards[i], data[:end], 0644))
+		data = data[end:]
+	}
+	return shards
+}
+
+// Test_TestPartial solves the shards of a file with every solution, writing
+// partial results, and checks merging them gives the result of the whole
+// file, which merging the rounded outputs could not.
+func Test_TestPartial(t *testing.T) {
+	fileName := writeMeasurements(t, randomNames(500), 60_000)
+	shards := splitLines(t, fileName, 3)
+	for _, sol := range []string{"sol1", "sol2", "sol3", "sol4", "sol5", "sol6"} {
+		run, err := solver.Lookup(sol)
+		assert.NoError(t, err)
+		want := run(fileName, common.Options{Workers: 2})
+
+		var results []common.Result
+		for _, shard := range shards {
+			var partial bytes.Buffer
+			out := run(shard, common.Options{Workers: 2, Partial: &partial})
+			r, err := common.ReadPartial(&partial)
+			if !assert.NoError(t, err, sol) {
+				return
+			}
+			assert.Equal(t, out, r.String(), sol)
+			results = append(results, r)
+		}
+		assert.Equal(t, want, common.Merge(results...).String(), sol)
+	}
+}
+
+func Test_TestPartialFormat(t *testing.T) {
+	r := common.Result{
+		{Name: "Abha", Min: -999, Max: 999, Sum: 1 << 40, Count: 3},
+		{Name: "Zürich", Min: 0, Max: 0, Sum: 0, Count: 1},
+	}
+	var buf bytes.Buffer
+	assert.NoError(t, common.WritePartial(&buf, r))
+	data := buf.Bytes()
+
+	got, err := common.ReadPartial(bytes.NewReader(data))
+	assert.NoError(t, err)
+	assert.Equal(t, r, got)
+
+	for name, corrupt := range map[string]func([]byte) []byte{
+		"magic":     func(b []byte) []byte { b[0] = 'x'; return b },
+		"version":   func(b []byte) []byte { b[4] = common.PartialVersion + 1; return b },
+		"flipped":   func(b []byte) []byte { b[10] ^= 1; return b },
+		"truncated": func(b []byte) []byte { return b[:len(b)-5] },
+		"empty":     func(b []byte) []byte { return nil },
+	} {
+		b := corrupt(bytes.Clone(data))
+		_, err := common.ReadPartial(bytes.NewReader(b))
+		assert.Error(t, err, name)
+	}
+
+	// merging keeps the extremes and adds up the sums and counts
+	merged := common.Merge(r, common.Result{{Name: "Abha", Min: -1000, Max: 5, Sum: 7, Count: 2}})
+	assert.Equal(t, common.Station{Name: "Abha", Min: -1000, Max: 999, Sum: 1<<40 + 7, Count: 5}, merged[0])
+	assert.Equal(t, r[1], merged[1])
+}
@@ -0,0 +1,26 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"runtime"
+
+	"github.com/draculaas/1brc/profile"
+)
+
+func profileInputCmd(args []string) error {
+	fs := newFlagSet("profile-input", "profile-input <file>")
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	p, err := profile.File(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	fmt.Print(p)
+	fmt.Printf("recommendation:    %s", p.Recommend(runtime.GOMAXPROCS(0)))
+	return nil
+}
@@ -0,0 +1,288 @@
+// Package profile samples a measurements file to describe the shape of its
+// data and recommends the solution and tuning that suit it best.
+package profile
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/draculaas/1brc/sol3"
+	"github.com/draculaas/1brc/sol4"
+	"github.com/draculaas/1brc/validate"
+)
+
+const (
+	sampleBlocks    = 64
+	sampleBlockSize = 64 << 10
+)
+
+// Histogram counts lines by length in bytes. Counts[i] holds the lines not
+// longer than Bounds[i], the last one also holds all the longer lines.
+type Histogram struct {
+	Bounds []int
+	Counts []int64
+}
+
+func newHistogram(bounds ...int) Histogram {
+	return Histogram{Bounds: bounds, Counts: make([]int64, len(bounds))}
+}
+
+func (h *Histogram) add(n int) {
+	i := sort.SearchInts(h.Bounds, n)
+	h.Counts[min(i, len(h.Counts)-1)]++
+}
+
+func (h Histogram) String() string {
+	var total int64
+	for _, c := range h.Counts {
+		total += c
+	}
+	parts := make([]string, len(h.Counts))
+	for i, c := range h.Counts {
+		bound := fmt.Sprintf("<=%d", h.Bounds[i])
+		if i == len(h.Counts)-1 {
+			bound = fmt.Sprintf(">%d", h.Bounds[i-1])
+		}
+		parts[i] = fmt.Sprintf("%s: %.1f%%", bound, percent(c, total))
+	}
+	return strings.Join(parts, ", ")
+}
+
+// Profile describes the data of a measurements file, estimated from a sample
+// of its lines. Shares are weighted by lines, not by stations.
+type Profile struct {
+	Size         int64
+	SampledBytes int64
+	SampledLines int64
+
+	// SampledStations are the unique stations seen in the sample, Stations is
+	// the estimate for the whole file.
+	SampledStations int
+	Stations        int
+
+	NameLen     Histogram
+	LineLen     Histogram
+	MeanNameLen float64
+	// MultiByte is the share of lines whose station name is not plain ASCII.
+	MultiByte float64
+
+	// Top1 and Top10 are the shares of lines of the most frequent station and
+	// of the ten most frequent ones.
+	Top1, Top10 float64
+
+	// Sol3Steps is the mean number of chain nodes sol3 visits per line.
+	Sol3Steps float64
+	// Sol4Probes is the mean number of slots sol4 probes per line.
+	Sol4Probes float64
+	// Sol4Conflicts counts the sampled stations sol4 cannot tell apart from
+	// another one, because they share a full hash or hash to the empty slot
+	// marker 0. sol4 gives wrong results on such files.
+	Sol4Conflicts int
+}
+
+type station struct {
+	name  string
+	count int64
+}
+
+// File profiles the measurements file fileName.
+func File(fileName string) (*Profile, error) {
+	f, err := os.Open(fileName)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	return Reader(f, info.Size())
+}
+
+// Reader profiles the measurements of size bytes read from r.
+func Reader(r io.ReaderAt, size int64) (*Profile, error) {
+	p := &Profile{
+		Size:    size,
+		NameLen: newHistogram(8, 16, 32, 64, 100),
+		LineLen: newHistogram(16, 32, 64, 128),
+	}
+
+	var stations []station
+	index := make(map[string]int)
+	var nameBytes, multiByte int64
+
+	whole := size <= sampleBlocks*sampleBlockSize
+	blocks, blockSize := sampleBlocks, int64(sampleBlockSize)
+	if whole {
+		blocks, blockSize = 1, size
+	}
+	buf := make([]byte, blockSize)
+
+	for i := 0; i < blocks; i++ {
+		var off int64
+		if blocks > 1 {
+			off = int64(i) * (size - blockSize) / int64(blocks-1)
+		}
+		n, err := r.ReadAt(buf, off)
+		if err != nil && err != io.EOF {
+			return nil, err
+		}
+		b := buf[:n]
+		p.SampledBytes += int64(n)
+
+		// only look at the complete lines of the block
+		if off > 0 {
+			b = b[bytes.IndexByte(b, '\n')+1:]
+		}
+		if off+int64(n) < size {
+			b = b[:bytes.LastIndexByte(b, '\n')+1]
+		}
+
+		for len(b) > 0 {
+			end := bytes.IndexByte(b, '\n')
+			if end < 0 {
+				end = len(b)
+			}
+			line := b[:end]
+			b = b[min(end+1, len(b)):]
+
+			sep := bytes.IndexByte(line, ';')
+			if sep < 0 {
+				continue
+			}
+			name := line[:sep]
+
+			p.SampledLines++
+			p.NameLen.add(len(name))
+			p.LineLen.add(len(line) + 1)
+			nameBytes += int64(len(name))
+			if utf8.RuneCount(name) != len(name) {
+				multiByte++
+			}
+
+			idx, ok := index[string(name)]
+			if !ok {
+				idx = len(stations)
+				index[string(name)] = idx
+				stations = append(stations, station{name: string(name)})
+			}
+			stations[idx].count++
+		}
+	}
+
+	if p.SampledLines == 0 {
+		return p, nil
+	}
+
+	p.SampledStations = len(stations)
+	p.Stations = len(stations)
+	if !whole {
+		p.Stations = min(estimateStations(stations), validate.MaxStations)
+	}
+	p.MeanNameLen = float64(nameBytes) / float64(p.SampledLines)
+	p.MultiByte = float64(multiByte) / float64(p.SampledLines)
+
+	p.Sol3Steps = sol3Steps(stations, p.SampledLines)
+	p.Sol4Probes, p.Sol4Conflicts = sol4Probes(stations, p.SampledLines)
+
+	counts := make([]int64, len(stations))
+	for i, s := range stations {
+		counts[i] = s.count
+	}
+	sort.Slice(counts, func(i, j int) bool { return counts[i] > counts[j] })
+	var top int64
+	for i, c := range counts[:min(10, len(counts))] {
+		top += c
+		if i == 0 {
+			p.Top1 = float64(top) / float64(p.SampledLines)
+		}
+	}
+	p.Top10 = float64(top) / float64(p.SampledLines)
+
+	return p, nil
+}
+
+// estimateStations estimates the unique stations of the whole file from the
+// ones seen in the sample with the bias-corrected Chao1 estimator.
+func estimateStations(stations []station) int {
+	var f1, f2 int
+	for _, s := range stations {
+		switch s.count {
+		case 1:
+			f1++
+		case 2:
+			f2++
+		}
+	}
+	return len(stations) + f1*(f1-1)/(2*(f2+1))
+}
+
+// sol3Steps replays the inserts of sol3's chained hash table and returns the
+// mean number of chain nodes visited per line.
+func sol3Steps(stations []station, lines int64) float64 {
+	chains := make(map[uint64]int)
+	var steps int64
+	for _, s := range stations {
+		var word [8]byte
+		copy(word[:], s.name)
+		idx := sol3.MakeHashKey(binary.LittleEndian.Uint64(word[:]), len(s.name)).Index()
+		chains[idx]++
+		steps += int64(chains[idx]) * s.count
+	}
+	return float64(steps) / float64(lines)
+}
+
+// sol4Probes replays the inserts of sol4's open addressing table and returns
+// the mean number of slots probed per line and the stations sol4 would mix up.
+func sol4Probes(stations []station, lines int64) (float64, int) {
+	slots := make([]uint64, sol4.Buckets)
+	var probes int64
+	conflicts := 0
+	for _, s := range stations {
+		hash := sol4.HashName([]byte(s.name))
+		if hash == 0 {
+			conflicts++
+			continue
+		}
+		n := int64(1)
+		for i := hash % sol4.Buckets; ; i = (i + 1) % sol4.Buckets {
+			if slots[i] == 0 {
+				slots[i] = hash
+				break
+			}
+			if slots[i] == hash {
+				conflicts++
+				break
+			}
+			n++
+		}
+		probes += n * s.count
+	}
+	return float64(probes) / float64(lines), conflicts
+}
+
+func percent(n, total int64) float64 {
+	if total == 0 {
+		return 0
+	}
+	return 100 * float64(n) / float64(total)
+}
+
+func (p *Profile) String() string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "size:              %d bytes, sampled %d bytes, %d lines\n", p.Size, p.SampledBytes, p.SampledLines)
+	fmt.Fprintf(&sb, "unique stations:   ~%d (%d sampled)\n", p.Stations, p.SampledStations)
+	fmt.Fprintf(&sb, "name length:       mean %.1f bytes, %s\n", p.MeanNameLen, p.NameLen)
+	fmt.Fprintf(&sb, "line length:       %s\n", p.LineLen)
+	fmt.Fprintf(&sb, "multi-byte names:  %.1f%% of lines\n", 100*p.MultiByte)
+	fmt.Fprintf(&sb, "key skew:          top station %.1f%%, top 10 stations %.1f%% of lines\n", 100*p.Top1, 100*p.Top10)
+	fmt.Fprintf(&sb, "sol3 chain steps:  %.2f per line\n", p.Sol3Steps)
+	fmt.Fprintf(&sb, "sol4 probes:       %.2f per line, %d conflicting stations\n", p.Sol4Probes, p.Sol4Conflicts)
+	return sb.String()
+}
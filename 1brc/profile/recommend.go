@@ -0,0 +1,81 @@
+package profile
+
+import (
+	"fmt"
+	"math/bits"
+	"strings"
+
+	"github.com/draculaas/1brc/common"
+)
+
+const (
+	// a hash table is considered degraded past this many steps per line. A
+	// sol3 step chases a pointer and may compare names, a sol4 probe only
+	// compares two hashes in neighbouring slots.
+	maxChainSteps = 4
+	maxProbes     = 16
+	// every worker should get at least this much of the file
+	minWorkerBytes = 4 << 20
+	// sol3 allocates a 256 MB table per worker, it only pays off on big files
+	minSol3Size = 256 << 20
+	// sol3 finds the separator 8 bytes at a time, sol4 byte by byte
+	longNameLen = 16
+)
+
+// Recommendation is the solution and tuning suiting a profiled file.
+type Recommendation struct {
+	Solution string
+	Options  common.Options
+	Reasons  []string
+}
+
+func (r Recommendation) String() string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "-sol %s -workers %d -chunk-size %d\n", r.Solution, r.Options.Workers, r.Options.ChunkSize)
+	for _, reason := range r.Reasons {
+		fmt.Fprintf(&sb, "  %s\n", reason)
+	}
+	return sb.String()
+}
+
+// Recommend picks the solution, worker count and chunk size for the profiled
+// file on a machine running procs goroutines in parallel.
+func (p *Profile) Recommend(procs int) Recommendation {
+	var r Recommendation
+
+	workers := int(min(max(p.Size/minWorkerBytes, 1), int64(procs)))
+	r.Options.Workers = workers
+	if workers < procs {
+		r.Reasons = append(r.Reasons, fmt.Sprintf("%d workers: the file is too small to keep %d busy", workers, procs))
+	}
+
+	// hand every worker a few blocks so the last ones even out the load,
+	// but keep the 1 MB default on big files
+	chunk := min(max(p.Size/int64(workers*8), 64<<10), 1<<20)
+	r.Options.ChunkSize = 1 << (bits.Len64(uint64(chunk)) - 1)
+
+	sol3Bad := p.Sol3Steps > maxChainSteps
+	sol4Bad := p.Sol4Probes > maxProbes || p.Sol4Conflicts > 0
+	if sol3Bad {
+		r.Reasons = append(r.Reasons, fmt.Sprintf("sol3 visits %.1f chain nodes per line", p.Sol3Steps))
+	}
+	if p.Sol4Conflicts > 0 {
+		r.Reasons = append(r.Reasons, fmt.Sprintf("sol4 cannot tell %d stations apart by their hash", p.Sol4Conflicts))
+	} else if sol4Bad {
+		r.Reasons = append(r.Reasons, fmt.Sprintf("sol4 probes %.1f slots per line", p.Sol4Probes))
+	}
+
+	switch {
+	case sol3Bad && sol4Bad:
+		r.Solution = "sol2"
+		r.Reasons = append(r.Reasons, "sol2: both custom hash tables degrade, the built-in map does not")
+	case sol4Bad:
+		r.Solution = "sol3"
+	case !sol3Bad && p.MeanNameLen >= longNameLen && p.Size >= minSol3Size:
+		r.Solution = "sol3"
+		r.Reasons = append(r.Reasons, fmt.Sprintf("sol3: names are %.1f bytes long on average, sol3 scans them 8 bytes at a time", p.MeanNameLen))
+	default:
+		r.Solution = "sol4"
+	}
+	return r
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/draculaas/1brc/collide"
+	"github.com/draculaas/1brc/common"
+	"github.com/draculaas/1brc/profile"
+	"github.com/draculaas/1brc/solver"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_TestAuto(t *testing.T) {
+	auto, err := solver.Lookup("auto")
+	assert.NoError(t, err)
+	for _, name := range find("./test_cases", ".txt") {
+		t.Run(name, func(t *testing.T) {
+			got := auto(name+".txt", common.Options{})
+			want := readFile(name + ".out")
+			assert.Equal(t, want, got)
+		})
+	}
+}
+
+func Test_TestProfile(t *testing.T) {
+	tests := []struct {
+		name  string
+		names []string
+		want  string
+	}{
+		{"random", randomNames(1000), "sol4"},
+		{"sol3-collisions", collide.Sol3(1000), "sol4"},
+		{"sol4-collisions", collide.Sol4(1000), "sol2"},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			p, err := profile.File(writeMeasurements(t, tc.names, 10_000))
+			assert.NoError(t, err)
+			assert.Equal(t, int64(10_000), p.SampledLines)
+			assert.Equal(t, 1000, p.Stations)
+			assert.InDelta(t, 0.001, p.Top1, 1e-9)
+
+			r := p.Recommend(4)
+			assert.Equal(t, tc.want, r.Solution)
+			assert.Equal(t, 1, r.Options.Workers)
+		})
+	}
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"testing"
+
+	"github.com/draculaas/1brc/sol3"
+	"github.com/draculaas/1brc/sol4"
+	"github.com/stretchr/testify/assert"
+)
+
+// portableInputs returns the test cases and a file of long random names,
+// each followed by zero padding, so the word loads of the fast versions
+// read the same zeros the portable versions assume past the end.
+func portableInputs(t *testing.T) [][]byte {
+	var inputs [][]byte
+	fileNames := []string{writeMeasurements(t, randomNames(1_000), 10_000)}
+	for _, name := range find("./test_cases", ".txt") {
+		fileNames = append(fileNames, name+".txt")
+	}
+	for _, fileName := range fileNames {
+		data, err := os.ReadFile(fileName)
+		assert.NoError(t, err)
+		inputs = append(inputs, append(data, make([]byte, 8)...)[:len(data)])
+	}
+	return inputs
+}
+
+// Test_TestPortable checks the fast and the portable hot routines of sol3
+// and sol4 agree. Built with -tags purego both are the portable ones.
+func Test_TestPortable(t *testing.T) {
+	for _, data := range portableInputs(t) {
+		for i := range uint64(len(data)) {
+			if got, want := sol3.Word(data, i), sol3.PortableWord(data, i); got != want {
+				t.Fatalf("sol3 word at %d: got %#x, want %#x", i, got, want)
+			}
+		}
+
+		for i := uintptr(0); i < uintptr(len(data)); {
+			hash, val, nameLen, lineLen := sol4.Parse(data, i)
+			wantHash, wantVal, wantNameLen, wantLineLen := sol4.ParsePortable(data, i)
+			line := data[i : i+uintptr(bytes.IndexByte(data[i:], '\n'))+1]
+			if hash != wantHash || val != wantVal || nameLen != wantNameLen || lineLen != wantLineLen {
+				t.Fatalf("sol4 parse %q: got (%d, %d, %d, %d), want (%d, %d, %d, %d)", line,
+					hash, val, nameLen, lineLen, wantHash, wantVal, wantNameLen, wantLineLen)
+			}
+			assert.Equal(t, uintptr(len(line)), lineLen)
+			i += lineLen
+		}
+	}
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"math/rand"
+	"os"
+	"testing"
+
+	"github.com/draculaas/1brc/common"
+	"github.com/draculaas/1brc/sol3"
+	"github.com/draculaas/1brc/sol4"
+	"github.com/draculaas/1brc/sol5"
+	"github.com/draculaas/1brc/solver"
+	"github.com/stretchr/testify/assert"
+)
+
+// Test_TestScan checks the scanner picked for this CPU agrees with the
+// portable one on every prefix length of a 64 byte group.
+func Test_TestScan(t *testing.T) {
+	t.Logf("AVX2 scanner: %v", sol5.Accelerated())
+	data, err := os.ReadFile("./test_cases/measurements-10000-unique-keys.txt")
+	assert.NoError(t, err)
+	data = append(data, make([]byte, common.Padding)...)[:len(data)]
+
+	for _, n := range []int{0, 1, 31, 32, 33, 63, 64, 65, 1000, len(data)} {
+		b := data[:n]
+		words := (n + 63) / 64
+		semis, newlines := make([]uint64, words), make([]uint64, words)
+		wantSemis, wantNewlines := make([]uint64, words), make([]uint64, words)
+		sol5.Scan(b, semis, newlines)
+		sol5.ScanGeneric(b, wantSemis, wantNewlines)
+		if words > 0 && n%64 != 0 {
+			// bits past len(b) are undefined
+			mask := uint64(1)<<(n%64) - 1
+			semis[words-1] &= mask
+			newlines[words-1] &= mask
+		}
+		assert.Equal(t, wantSemis, semis, "%d bytes", n)
+		assert.Equal(t, wantNewlines, newlines, "%d bytes", n)
+	}
+}
+
+// namesBetween returns n distinct random names of min to max lowercase
+// letters.
+func namesBetween(n, minLen, maxLen int) []string {
+	rng := rand.New(rand.NewSource(1))
+	seen := make(map[string]bool, n)
+	names := make([]string, 0, n)
+	for len(names) < n {
+		b := make([]byte, minLen+rng.Intn(maxLen-minLen+1))
+		for i := range b {
+			b[i] = byte('a' + rng.Intn(26))
+		}
+		if !seen[string(b)] {
+			seen[string(b)] = true
+			names = append(names, string(b))
+		}
+	}
+	return names
+}
+
+func BenchmarkScan(b *testing.B) {
+	data, err := os.ReadFile(writeMeasurements(b, randomNames(10_000), 100_000))
+	if err != nil {
+		b.Fatal(err)
+	}
+	data = append(data, make([]byte, common.Padding)...)[:len(data)]
+	semis := make([]uint64, (len(data)+63)/64)
+	newlines := make([]uint64, len(semis))
+
+	for _, s := range []struct {
+		name string
+		scan func(b []byte, semis, newlines []uint64)
+	}{
+		{"picked", sol5.Scan},
+		{"generic", sol5.ScanGeneric},
+	} {
+		b.Run(s.name, func(b *testing.B) {
+			b.SetBytes(int64(len(data)))
+			for i := 0; i < b.N; i++ {
+				s.scan(data, semis, newlines)
+			}
+		})
+	}
+}
+
+// BenchmarkNames compares the separator search of sol3 (8 byte words), sol4
+// (byte by byte) and sol5 (32 byte vectors) on short and long names.
+func BenchmarkNames(b *testing.B) {
+	for _, names := range []struct {
+		name     string
+		min, max int
+	}{
+		{"short", 3, 8},
+		{"long", 60, 100},
+	} {
+		fileName := writeMeasurements(b, namesBetween(1_000, names.min, names.max), 1_000_000)
+		for _, sol := range []struct {
+			name string
+			run  solver.Func
+		}{
+			{"sol3", sol3.RunWith},
+			{"sol4", sol4.RunWith},
+			{"sol5", sol5.RunWith},
+		} {
+			b.Run(names.name+"/"+sol.name, func(b *testing.B) {
+				for i := 0; i < b.N; i++ {
+					sol.run(fileName, common.Options{})
+				}
+			})
+		}
+	}
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/draculaas/1brc/common"
+	"github.com/draculaas/1brc/sol2"
+	"github.com/draculaas/1brc/sol3"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_TestSchedule(t *testing.T) {
+	data, err := os.ReadFile(writeMeasurements(t, randomNames(100), 20_000))
+	assert.NoError(t, err)
+
+	var mu sync.Mutex
+	covered := make([]int, len(data))
+	stats := common.Schedule(data, 4096, 4, func(worker, start, end int) {
+		// a slow worker leaves its ranges to the others
+		if worker == 0 {
+			time.Sleep(time.Millisecond)
+		}
+		assert.True(t, start == 0 || data[start-1] == '\n')
+		assert.Equal(t, byte('\n'), data[end-1])
+		mu.Lock()
+		for i := start; i < end; i++ {
+			covered[i]++
+		}
+		mu.Unlock()
+	})
+
+	for i, n := range covered {
+		if n != 1 {
+			t.Fatalf("byte %d processed %d times", i, n)
+		}
+	}
+	ranges, stolen := 0, 0
+	for _, st := range stats {
+		ranges += st.Ranges
+		stolen += st.Stolen
+	}
+	// ranges end at the first line end after 4 KiB
+	assert.InDelta(t, len(data)/4096, ranges, 2)
+	assert.Less(t, stats[0].Ranges, ranges/4, "the slow worker keeps its share")
+	assert.Positive(t, stolen)
+
+	fileName := writeMeasurements(t, randomNames(100), 20_000)
+	want := sol2.RunWith(fileName, common.Options{Workers: 1})
+	for _, run := range []func(string, common.Options) string{sol2.RunWith, sol3.RunWith} {
+		var report strings.Builder
+		got := run(fileName, common.Options{Workers: 3, ChunkSize: 1000, Report: &report})
+		assert.Equal(t, want, got)
+		assert.Equal(t, 3, strings.Count(report.String(), "busy"))
+	}
+}
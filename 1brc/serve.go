@@ -0,0 +1,28 @@
+package main
+
+import (
+	"log"
+	"net/http"
+	"os"
+
+	"github.com/draculaas/1brc/server"
+)
+
+func serveCmd(args []string) error {
+	fs := newFlagSet("serve", "serve [-addr addr] [-root dir] [-jobs n] [-workers n]")
+	addr := fs.String("addr", "127.0.0.1:8080", "address to serve on")
+	root := fs.String("root", "./data", "directory of the files aggregated by path, empty serves none")
+	jobs := fs.Int("jobs", 0, "requests aggregated at once, the others wait, 0 allows one per worker")
+	workers := fs.Int("workers", 0, "goroutines shared by all the requests, 0 uses GOMAXPROCS")
+	blockSize := fs.Int("chunk-size", 0, "size in bytes of the blocks handed to the workers, 0 keeps the default")
+	fs.Parse(args)
+	if fs.NArg() != 0 {
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	s := server.New(server.Config{Root: *root, Workers: *workers, MaxJobs: *jobs, BlockSize: *blockSize})
+	defer s.Close()
+	log.Printf("serving on http://%s/aggregate", *addr)
+	return http.ListenAndServe(*addr, s)
+}
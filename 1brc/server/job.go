@@ -0,0 +1,169 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"sync"
+
+	"github.com/draculaas/1brc/common"
+	"github.com/draculaas/1brc/sol4"
+	"github.com/draculaas/1brc/validate"
+)
+
+// inputError is a request body or file breaking the rules of the challenge.
+type inputError struct {
+	msg string
+}
+
+func (e *inputError) Error() string { return e.msg }
+
+// table aggregates the lines of one job on one worker of the pool. Unlike
+// the table of sol4 it grows, refuses more stations than the rules allow and
+// tells stations apart by name, not by hash, as requests are not trusted and
+// names colliding under the hash of sol4 are easy to build.
+type table struct {
+	index    map[string]int
+	stations common.Result
+}
+
+func newTable() *table {
+	return &table{index: make(map[string]int)}
+}
+
+// add aggregates the validated lines in b, followed by common.Padding
+// readable bytes for the parse loop of sol4.
+func (t *table) add(b []byte) error {
+	for start := uintptr(0); start < uintptr(len(b)); {
+		_, val, nameLen, lineLen := sol4.Parse(b, start)
+		name := b[start : start+nameLen]
+		i, ok := t.index[string(name)]
+		if !ok {
+			if len(t.stations) == validate.MaxStations {
+				return &inputError{fmt.Sprintf("more than %d unique stations", validate.MaxStations)}
+			}
+			i = len(t.stations)
+			t.stations = append(t.stations, common.Station{Name: string(name), Min: val, Max: val})
+			t.index[t.stations[i].Name] = i
+		}
+		s := &t.stations[i]
+		s.Min = min(s.Min, val)
+		s.Max = max(s.Max, val)
+		s.Sum += val
+		s.Count++
+		start += lineLen
+	}
+	return nil
+}
+
+// aggregate reads the measurements from r in blocks of blockSize bytes,
+// which the workers of p validate and aggregate. It stops early once ctx is
+// done.
+func aggregate(ctx context.Context, p *pool, r io.Reader, blockSize int) (common.Result, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	tables := make([]*table, p.size)
+	var (
+		wg     sync.WaitGroup
+		mu     sync.Mutex
+		failed error
+	)
+	fail := func(err error) {
+		mu.Lock()
+		if failed == nil {
+			failed = err
+			cancel()
+		}
+		mu.Unlock()
+	}
+
+	err := readBlocks(ctx, r, blockSize, func(off int64, block []byte) {
+		wg.Add(1)
+		task := func(worker int) {
+			defer wg.Done()
+			if ctx.Err() != nil {
+				return
+			}
+			if v, ok := validate.Lines(block); !ok {
+				fail(&inputError{fmt.Sprintf("line %d of the block at byte %d: %s", v.Line, off, v.Msg)})
+				return
+			}
+			if tables[worker] == nil {
+				tables[worker] = newTable()
+			}
+			if err := tables[worker].add(block); err != nil {
+				fail(err)
+			}
+		}
+		select {
+		case p.tasks <- task:
+		case <-ctx.Done():
+			wg.Done()
+		}
+	})
+	wg.Wait()
+
+	if failed != nil {
+		return nil, failed
+	}
+	if err != nil {
+		return nil, err
+	}
+	results := make([]common.Result, 0, len(tables))
+	for _, t := range tables {
+		if t != nil {
+			results = append(results, t.stations)
+		}
+	}
+	merged := common.Merge(results...)
+	if len(merged) > validate.MaxStations {
+		return nil, &inputError{fmt.Sprintf("more than %d unique stations", validate.MaxStations)}
+	}
+	return merged, nil
+}
+
+// readBlocks reads r in blocks of about blockSize bytes holding complete
+// lines and calls fn with every block and its offset, until r ends or ctx is
+// done. Every block is a new buffer followed by common.Padding bytes, and a
+// missing newline at the end is added.
+func readBlocks(ctx context.Context, r io.Reader, blockSize int, fn func(off int64, block []byte)) error {
+	var (
+		carry []byte
+		off   int64
+	)
+	for ctx.Err() == nil {
+		buf := make([]byte, blockSize+common.Padding)
+		n := copy(buf, carry)
+		m, err := io.ReadFull(r, buf[n:blockSize])
+		n += m
+		last := err == io.EOF || err == io.ErrUnexpectedEOF
+		if err != nil && !last {
+			return err
+		}
+
+		end := n
+		if !last {
+			i := bytes.LastIndexByte(buf[:n], '\n')
+			if i < 0 {
+				return &inputError{fmt.Sprintf("line at byte %d is longer than %d bytes", off, blockSize)}
+			}
+			end = i + 1
+		} else if n > 0 && buf[n-1] != '\n' {
+			buf[n] = '\n'
+			n++
+			end = n
+		}
+		carry = append(carry[:0:0], buf[end:n]...)
+
+		if end > 0 {
+			fn(off, buf[:end])
+		}
+		off += int64(end)
+		if last {
+			return nil
+		}
+	}
+	return ctx.Err()
+}
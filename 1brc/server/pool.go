@@ -0,0 +1,25 @@
+package server
+
+// pool runs the blocks of every job on a fixed set of goroutines, so
+// concurrent requests share the cores instead of each starting its own
+// workers.
+type pool struct {
+	tasks chan func(worker int)
+	size  int
+}
+
+func newPool(size int) *pool {
+	p := &pool{tasks: make(chan func(worker int)), size: size}
+	for i := 0; i < size; i++ {
+		go func(worker int) {
+			for task := range p.tasks {
+				task(worker)
+			}
+		}(i)
+	}
+	return p
+}
+
+func (p *pool) close() {
+	close(p.tasks)
+}
@@ -0,0 +1,144 @@
+// Package server aggregates measurements over HTTP, from files on the
+// server or from request bodies, on a pool of workers shared by all
+// requests.
+package server
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"runtime"
+
+	"github.com/draculaas/1brc/common"
+)
+
+// Config tunes a Server. Zero values pick the defaults.
+type Config struct {
+	// Root is the directory of the files aggregated by path, none are
+	// served without it.
+	Root string
+	// Workers is the number of goroutines of the pool shared by the jobs,
+	// GOMAXPROCS by default.
+	Workers int
+	// MaxJobs is the number of requests aggregated at once, the others wait
+	// for a slot. Workers by default.
+	MaxJobs int
+	// BlockSize is the size in bytes of the blocks handed to the workers,
+	// common.DefaultBlockSize by default.
+	BlockSize int
+}
+
+var contentTypes = map[string]string{
+	"text":    "text/plain; charset=utf-8",
+	"json":    "application/json",
+	"csv":     "text/csv; charset=utf-8",
+	"partial": "application/octet-stream",
+}
+
+// Server serves the aggregation endpoint:
+//
+//	GET  /aggregate?path=<file under Root>[&format=<format>]
+//	POST /aggregate[?format=<format>] with the measurements as body
+//
+// The format is one of common.Formats, text by default. A request that is
+// canceled stops its job.
+type Server struct {
+	cfg  Config
+	pool *pool
+	jobs chan struct{}
+	mux  *http.ServeMux
+}
+
+// New starts the worker pool of a server, see Close.
+func New(cfg Config) *Server {
+	if cfg.Workers <= 0 {
+		cfg.Workers = runtime.GOMAXPROCS(0)
+	}
+	if cfg.MaxJobs <= 0 {
+		cfg.MaxJobs = cfg.Workers
+	}
+	if cfg.BlockSize <= 0 {
+		cfg.BlockSize = common.DefaultBlockSize
+	}
+	// a block must hold at least one line
+	cfg.BlockSize = max(cfg.BlockSize, common.MaxLineLen+1)
+
+	s := &Server{
+		cfg:  cfg,
+		pool: newPool(cfg.Workers),
+		jobs: make(chan struct{}, cfg.MaxJobs),
+		mux:  http.NewServeMux(),
+	}
+	s.mux.HandleFunc("/aggregate", s.aggregate)
+	return s
+}
+
+// Close stops the worker pool, once no request is served anymore.
+func (s *Server) Close() {
+	s.pool.close()
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mux.ServeHTTP(w, r)
+}
+
+func (s *Server) aggregate(w http.ResponseWriter, r *http.Request) {
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		format = "text"
+	}
+	if _, ok := common.Formats[format]; !ok {
+		http.Error(w, fmt.Sprintf("unknown format %q, want one of %v", format, common.FormatNames()), http.StatusBadRequest)
+		return
+	}
+
+	var in io.Reader
+	switch name := r.URL.Query().Get("path"); {
+	case name != "":
+		if s.cfg.Root == "" {
+			http.Error(w, "no files are served", http.StatusForbidden)
+			return
+		}
+		// cleaned as an absolute path, it cannot climb out of the root
+		f, err := os.Open(filepath.Join(s.cfg.Root, filepath.FromSlash(path.Clean("/"+name))))
+		if err != nil {
+			http.Error(w, fmt.Sprintf("cannot open %s", name), http.StatusNotFound)
+			return
+		}
+		defer f.Close()
+		in = f
+	case r.Method == http.MethodPost:
+		in = r.Body
+	default:
+		http.Error(w, "want a path or a POST of measurements", http.StatusBadRequest)
+		return
+	}
+
+	select {
+	case s.jobs <- struct{}{}:
+		defer func() { <-s.jobs }()
+	case <-r.Context().Done():
+		return
+	}
+
+	res, err := aggregate(r.Context(), s.pool, in, s.cfg.BlockSize)
+	var bad *inputError
+	switch {
+	case r.Context().Err() != nil:
+		// nobody is waiting for the answer
+		return
+	case errors.As(err, &bad):
+		http.Error(w, bad.Error(), http.StatusBadRequest)
+		return
+	case err != nil:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", contentTypes[format])
+	common.WriteFormat(w, format, res)
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/draculaas/1brc/common"
+	"github.com/draculaas/1brc/server"
+	"github.com/stretchr/testify/assert"
+)
+
+// get returns the status and body of a request to the test server.
+func get(t *testing.T, req *http.Request) (int, string) {
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+	body, err := io.ReadAll(res.Body)
+	assert.NoError(t, err)
+	return res.StatusCode, string(body)
+}
+
+func Test_TestServer(t *testing.T) {
+	root := t.TempDir()
+	s := server.New(server.Config{Root: root, Workers: 3, BlockSize: 256})
+	defer s.Close()
+	ts := httptest.NewServer(s)
+	defer ts.Close()
+
+	for _, name := range find("./test_cases", ".txt") {
+		data, err := os.ReadFile(name + ".txt")
+		assert.NoError(t, err)
+		want := readFile(name + ".out")
+
+		req, _ := http.NewRequest("POST", ts.URL+"/aggregate", strings.NewReader(string(data)))
+		status, body := get(t, req)
+		assert.Equal(t, http.StatusOK, status, name)
+		assert.Equal(t, want, body, name)
+
+		assert.NoError(t, os.WriteFile(filepath.Join(root, "m.txt"), data, 0644))
+		req, _ = http.NewRequest("GET", ts.URL+"/aggregate?path=m.txt", nil)
+		status, body = get(t, req)
+		assert.Equal(t, http.StatusOK, status, name)
+		assert.Equal(t, want, body, name)
+	}
+
+	// other formats, and a body not ending in a newline
+	req, _ := http.NewRequest("POST", ts.URL+"/aggregate?format=json", strings.NewReader("a;1.0\nb;-2.5\na;3.0"))
+	status, body := get(t, req)
+	assert.Equal(t, http.StatusOK, status)
+	var stations []map[string]any
+	assert.NoError(t, json.Unmarshal([]byte(body), &stations))
+	assert.Equal(t, []map[string]any{
+		{"name": "a", "min": 1.0, "mean": 2.0, "max": 3.0, "count": 2.0},
+		{"name": "b", "min": -2.5, "mean": -2.5, "max": -2.5, "count": 1.0},
+	}, stations)
+
+	req, _ = http.NewRequest("POST", ts.URL+"/aggregate?format=partial", strings.NewReader("a;1.0\n"))
+	status, body = get(t, req)
+	assert.Equal(t, http.StatusOK, status)
+	r, err := common.ReadPartial(strings.NewReader(body))
+	assert.NoError(t, err)
+	assert.Equal(t, common.Result{{Name: "a", Min: 10, Max: 10, Sum: 10, Count: 1}}, r)
+
+	for query, in := range map[string]string{
+		"?format=yaml":          "a;1.0\n",
+		"":                      "a;1.0\nb;1.00\n",
+		"?path=../../etc/hosts": "",
+		"?path=missing.txt":     "",
+	} {
+		req, _ := http.NewRequest("POST", ts.URL+"/aggregate"+query, strings.NewReader(in))
+		status, body := get(t, req)
+		assert.NotEqual(t, http.StatusOK, status, "%s %q: %s", query, in, body)
+	}
+	req, _ = http.NewRequest("POST", ts.URL+"/aggregate", strings.NewReader("a;1.0\nb;1.00\n"))
+	_, body = get(t, req)
+	assert.Contains(t, body, "line 2")
+
+	// names with the same hash stay apart
+	in := sol4Twins[0] + ";1.0\n" + sol4Twins[1] + ";3.0\n"
+	req, _ = http.NewRequest("POST", ts.URL+"/aggregate", strings.NewReader(in))
+	status, body = get(t, req)
+	assert.Equal(t, http.StatusOK, status)
+	assert.Equal(t, "{"+sol4Twins[1]+"=3.0/3.0/3.0, "+sol4Twins[0]+"=1.0/1.0/1.0}\n", body)
+}
+
+// Test_TestServerCancel holds the only job slot with a request streaming its
+// body, and checks canceling it frees the slot for the next request.
+func Test_TestServerCancel(t *testing.T) {
+	s := server.New(server.Config{Workers: 2, MaxJobs: 1})
+	defer s.Close()
+	ts := httptest.NewServer(s)
+	defer ts.Close()
+
+	pr, pw := io.Pipe()
+	ctx, cancel := context.WithCancel(context.Background())
+	req, _ := http.NewRequestWithContext(ctx, "POST", ts.URL+"/aggregate", pr)
+	done := make(chan error)
+	go func() {
+		_, err := http.DefaultClient.Do(req)
+		done <- err
+	}()
+	// the server reads the body once it holds the slot, so writing more
+	// than the connection buffers returns with the slot taken
+	_, err := pw.Write(bytes.Repeat([]byte("a;1.0\n"), 16<<20/6))
+	assert.NoError(t, err)
+
+	waiting, cancelWaiting := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancelWaiting()
+	req, _ = http.NewRequestWithContext(waiting, "POST", ts.URL+"/aggregate", strings.NewReader("b;2.0\n"))
+	_, err = http.DefaultClient.Do(req)
+	assert.ErrorIs(t, err, context.DeadlineExceeded, "the slot is taken")
+
+	// the client sends the body until it ends, even canceled
+	cancel()
+	pw.CloseWithError(context.Canceled)
+	assert.ErrorIs(t, <-done, context.Canceled)
+
+	req, _ = http.NewRequest("POST", ts.URL+"/aggregate?"+url.Values{"format": {"csv"}}.Encode(), strings.NewReader("b;2.0\n"))
+	status, body := get(t, req)
+	assert.Equal(t, http.StatusOK, status)
+	assert.Equal(t, "name,min,mean,max,count\nb,2.0,2.0,2.0,1\n", body)
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"fmt"
+	"runtime"
+	"testing"
+
+	"github.com/draculaas/1brc/common"
+	"github.com/draculaas/1brc/sol1"
+	"github.com/draculaas/1brc/sol4"
+	"github.com/draculaas/1brc/sol6"
+	"github.com/draculaas/1brc/solver"
+	"github.com/stretchr/testify/assert"
+)
+
+// Test_TestShared has many workers claim and update the shared table of
+// sol6 at once, on few stations so they contend on every slot.
+func Test_TestShared(t *testing.T) {
+	for _, stations := range []int{3, 400} {
+		fileName := writeMeasurements(t, randomNames(stations), 50_000)
+		got := sol6.RunWith(fileName, common.Options{Workers: 16, ChunkSize: 1024})
+		assert.Equal(t, sol1.Run(fileName), got, "%d stations", stations)
+	}
+}
+
+// BenchmarkShared compares the shared table of sol6 with the per-worker
+// tables and merge of sol4 from one core to all of them, on 400 and 10k
+// stations.
+func BenchmarkShared(b *testing.B) {
+	var procs []int
+	for n := 1; n < runtime.NumCPU(); n *= 2 {
+		procs = append(procs, n)
+	}
+	procs = append(procs, runtime.NumCPU())
+
+	for _, stations := range []int{400, 10_000} {
+		fileName := writeMeasurements(b, randomNames(stations), 2_000_000)
+		for _, n := range procs {
+			for _, sol := range []struct {
+				name string
+				run  solver.Func
+			}{
+				{"sol4", sol4.RunWith},
+				{"sol6", sol6.RunWith},
+			} {
+				b.Run(fmt.Sprintf("%d/%d/%s", stations, n, sol.name), func(b *testing.B) {
+					defer runtime.GOMAXPROCS(runtime.GOMAXPROCS(n))
+					for i := 0; i < b.N; i++ {
+						sol.run(fileName, common.Options{Workers: n})
+					}
+				})
+			}
+		}
+	}
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/draculaas/1brc/common"
+	"github.com/draculaas/1brc/shell"
+	"github.com/draculaas/1brc/sol4"
+)
+
+func shellCmd(args []string) error {
+	fs := newFlagSet("shell", "shell [-workers n] <measurements or partial file>")
+	workers := fs.Int("workers", 0, "goroutines solving a measurements file, 0 keeps the default of sol4")
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	start := time.Now()
+	r, err := loadResult(fs.Arg(0), common.Options{Workers: *workers})
+	if err != nil {
+		return err
+	}
+	fmt.Printf("loaded %s in %v\n", fs.Arg(0), time.Since(start))
+	return shell.New(r, os.Stdout).Run(os.Stdin)
+}
+
+// loadResult reads the partial file fileName, or solves it with sol4 if it
+// holds measurements.
+func loadResult(fileName string, opts common.Options) (common.Result, error) {
+	r, err := common.ReadPartialFile(fileName)
+	if !errors.Is(err, common.ErrNotPartial) {
+		return r, err
+	}
+	src, err := common.OpenSource("mmap", fileName)
+	if err != nil {
+		return nil, err
+	}
+	defer src.Close()
+	return sol4.AggregateRange(src, 0, src.Size(), opts), nil
+}
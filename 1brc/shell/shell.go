@@ -0,0 +1,243 @@
+// Package shell answers queries over the stations of a result, so a file
+// solved once can be explored without solving it again.
+package shell
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/draculaas/1brc/common"
+)
+
+const usage = `commands:
+  get <station>                 the stats of a station
+  prefix <text>                 the stations starting with text
+  top <k> <stat>                the k stations with the highest stat
+  bottom <k> <stat>             the k stations with the lowest stat
+  filter <stat> <lo> <hi>       the stations with lo <= stat <= hi
+  hist <stat> [buckets]         histogram of a stat over the stations
+  export <format> <file>        write the stations listed last, all before any
+  help                          this text
+  quit                          leave the shell
+stats: min, mean, max, spread (max - min) and count
+formats: `
+
+// stats are the stats the stations can be ranked, filtered and counted by.
+var stats = map[string]func(s common.Station) float64{
+	"min":    func(s common.Station) float64 { return common.Round(float64(s.Min) / 10.0) },
+	"mean":   func(s common.Station) float64 { return s.Mean() },
+	"max":    func(s common.Station) float64 { return common.Round(float64(s.Max) / 10.0) },
+	"spread": func(s common.Station) float64 { return common.Round(float64(s.Max-s.Min) / 10.0) },
+	"count":  func(s common.Station) float64 { return float64(s.Count) },
+}
+
+// errQuit ends Run.
+var errQuit = errors.New("quit")
+
+// Shell answers the commands of usage over the stations of a result.
+type Shell struct {
+	all  common.Result
+	last common.Result
+	out  io.Writer
+}
+
+// New returns a shell over the stations of r writing the answers to out.
+func New(r common.Result, out io.Writer) *Shell {
+	r = slices.Clone(r)
+	r.Sort()
+	return &Shell{all: r, last: r, out: out}
+}
+
+// Run reads commands from in, one per line, until it ends or a quit
+// command. Failed commands print their error and the shell goes on.
+func (s *Shell) Run(in io.Reader) error {
+	fmt.Fprintf(s.out, "%d stations, type help for the commands\n", len(s.all))
+	sc := bufio.NewScanner(in)
+	for {
+		fmt.Fprint(s.out, "> ")
+		if !sc.Scan() {
+			fmt.Fprintln(s.out)
+			return sc.Err()
+		}
+		err := s.Exec(sc.Text())
+		if err == errQuit {
+			return nil
+		}
+		if err != nil {
+			fmt.Fprintln(s.out, "error:", err)
+		}
+	}
+}
+
+// Exec runs one command.
+func (s *Shell) Exec(line string) error {
+	cmd, rest, _ := strings.Cut(strings.TrimSpace(line), " ")
+	rest = strings.TrimSpace(rest)
+	args := strings.Fields(rest)
+
+	switch cmd {
+	case "":
+		return nil
+	case "get":
+		i, ok := s.find(rest)
+		if !ok {
+			return fmt.Errorf("no station %q", rest)
+		}
+		s.list(s.all[i : i+1])
+	case "prefix":
+		i, _ := s.find(rest)
+		j := i
+		for j < len(s.all) && strings.HasPrefix(s.all[j].Name, rest) {
+			j++
+		}
+		s.list(s.all[i:j])
+	case "top", "bottom":
+		if len(args) != 2 {
+			return fmt.Errorf("want %s <k> <stat>", cmd)
+		}
+		k, err := strconv.Atoi(args[0])
+		if err != nil || k < 0 {
+			return fmt.Errorf("k %q is not a count", args[0])
+		}
+		stat, err := lookupStat(args[1])
+		if err != nil {
+			return err
+		}
+		ranked := slices.Clone(s.all)
+		// stable, so ties stay sorted by name
+		sort.SliceStable(ranked, func(i, j int) bool {
+			if cmd == "top" {
+				return stat(ranked[i]) > stat(ranked[j])
+			}
+			return stat(ranked[i]) < stat(ranked[j])
+		})
+		s.list(ranked[:min(k, len(ranked))])
+	case "filter":
+		if len(args) != 3 {
+			return errors.New("want filter <stat> <lo> <hi>")
+		}
+		stat, err := lookupStat(args[0])
+		if err != nil {
+			return err
+		}
+		lo, err1 := strconv.ParseFloat(args[1], 64)
+		hi, err2 := strconv.ParseFloat(args[2], 64)
+		if err1 != nil || err2 != nil {
+			return fmt.Errorf("bounds %q and %q are not numbers", args[1], args[2])
+		}
+		var matched common.Result
+		for _, st := range s.all {
+			if v := stat(st); lo <= v && v <= hi {
+				matched = append(matched, st)
+			}
+		}
+		s.list(matched)
+	case "hist":
+		if len(args) != 1 && len(args) != 2 {
+			return errors.New("want hist <stat> [buckets]")
+		}
+		stat, err := lookupStat(args[0])
+		if err != nil {
+			return err
+		}
+		buckets := 10
+		if len(args) == 2 {
+			if buckets, err = strconv.Atoi(args[1]); err != nil || buckets <= 0 {
+				return fmt.Errorf("buckets %q is not a count", args[1])
+			}
+		}
+		s.histogram(stat, buckets)
+	case "export":
+		if len(args) != 2 {
+			return errors.New("want export <format> <file>")
+		}
+		if _, ok := common.Formats[args[0]]; !ok {
+			return fmt.Errorf("unknown format %q, want one of %v", args[0], common.FormatNames())
+		}
+		return s.export(args[0], args[1])
+	case "help":
+		fmt.Fprintln(s.out, usage+strings.Join(common.FormatNames(), ", "))
+	case "quit", "exit":
+		return errQuit
+	default:
+		return fmt.Errorf("unknown command %q, type help for the commands", cmd)
+	}
+	return nil
+}
+
+// find returns the index of the station named name, or where it would be.
+func (s *Shell) find(name string) (int, bool) {
+	return sort.Find(len(s.all), func(i int) int {
+		return strings.Compare(name, s.all[i].Name)
+	})
+}
+
+// list prints stations and keeps them for export.
+func (s *Shell) list(stations common.Result) {
+	for _, st := range stations {
+		fmt.Fprintf(s.out, "%s=%.1f/%.1f/%.1f count=%d\n", st.Name,
+			common.Round(float64(st.Min)/10.0), st.Mean(), common.Round(float64(st.Max)/10.0), st.Count)
+	}
+	fmt.Fprintf(s.out, "(%d stations)\n", len(stations))
+	s.last = stations
+}
+
+// histogram prints how many stations fall in each of buckets equal ranges of
+// the values of stat.
+func (s *Shell) histogram(stat func(common.Station) float64, buckets int) {
+	if len(s.all) == 0 {
+		return
+	}
+	lo, hi := math.Inf(1), math.Inf(-1)
+	for _, st := range s.all {
+		lo, hi = min(lo, stat(st)), max(hi, stat(st))
+	}
+	width := (hi - lo) / float64(buckets)
+	counts := make([]int, buckets)
+	for _, st := range s.all {
+		i := buckets - 1
+		if width > 0 {
+			i = min(int((stat(st)-lo)/width), buckets-1)
+		}
+		counts[i]++
+	}
+
+	most := slices.Max(counts)
+	for i, n := range counts {
+		bar := strings.Repeat("#", (n*50+most-1)/most)
+		fmt.Fprintf(s.out, "%10.1f..%-10.1f %6d %s\n", lo+float64(i)*width, lo+float64(i+1)*width, n, bar)
+	}
+}
+
+// export writes the stations listed last to fileName in format.
+func (s *Shell) export(format, fileName string) error {
+	f, err := os.Create(fileName)
+	if err != nil {
+		return err
+	}
+	if err := common.WriteFormat(f, format, s.last); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	fmt.Fprintf(s.out, "wrote %d stations to %s\n", len(s.last), fileName)
+	return nil
+}
+
+func lookupStat(name string) (func(common.Station) float64, error) {
+	stat, ok := stats[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown stat %q, want min, mean, max, spread or count", name)
+	}
+	return stat, nil
+}
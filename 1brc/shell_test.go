@@ -0,0 +1,70 @@
+package main
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/draculaas/1brc/common"
+	"github.com/draculaas/1brc/shell"
+	"github.com/draculaas/1brc/sol1"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_TestShell(t *testing.T) {
+	fileName := writeMeasurements(t, []string{"Oslo", "Osaka", "Lima", "Accra"}, 1_000)
+	r, err := loadResult(fileName, common.Options{})
+	assert.NoError(t, err)
+	assert.Equal(t, sol1.Run(fileName), r.String())
+
+	// a partial file loads the same stations without solving
+	partial := filepath.Join(t.TempDir(), "m.part")
+	assert.NoError(t, common.WritePartialFile(partial, r))
+	loaded, err := loadResult(partial, common.Options{})
+	assert.NoError(t, err)
+	assert.Equal(t, r, loaded)
+
+	var out strings.Builder
+	s := shell.New(r, &out)
+	exec := func(line string) string {
+		out.Reset()
+		assert.NoError(t, s.Exec(line), line)
+		return out.String()
+	}
+	names := func(listing string) []string {
+		var res []string
+		for _, line := range strings.Split(listing, "\n") {
+			if name, _, ok := strings.Cut(line, "="); ok {
+				res = append(res, name)
+			}
+		}
+		return res
+	}
+
+	assert.Equal(t, []string{"Lima"}, names(exec("get Lima")))
+	assert.Contains(t, exec("get Lima"), "count=250")
+	assert.Equal(t, []string{"Osaka", "Oslo"}, names(exec("prefix Os")))
+	assert.Empty(t, names(exec("prefix X")))
+	assert.Len(t, names(exec("top 2 max")), 2)
+	assert.Len(t, names(exec("bottom 9 count")), 4)
+	assert.Len(t, names(exec("filter count 250 250")), 4)
+	assert.Empty(t, names(exec("filter mean 100 200")))
+	assert.Len(t, strings.Split(strings.TrimSpace(exec("hist mean 3")), "\n"), 3)
+
+	exec("prefix Os")
+	exported := filepath.Join(t.TempDir(), "os.part")
+	exec("export partial " + exported)
+	got, err := common.ReadPartialFile(exported)
+	assert.NoError(t, err)
+	assert.Equal(t, r[2:], got)
+
+	for _, bad := range []string{"get Paris", "top x max", "top 2 median", "filter mean 1", "hist", "export yaml x", "frobnicate"} {
+		assert.Error(t, s.Exec(bad), bad)
+	}
+
+	out.Reset()
+	assert.NoError(t, s.Run(strings.NewReader("get Oslo\nnope\nquit\nget Lima\n")))
+	assert.Contains(t, out.String(), "Oslo=")
+	assert.Contains(t, out.String(), "error: unknown command")
+	assert.NotContains(t, out.String(), "Lima=")
+}
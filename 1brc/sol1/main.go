@@ -2,11 +2,10 @@ package sol1
 
 import (
 	"bufio"
-	"fmt"
+	"bytes"
 	"github.com/draculaas/1brc/common"
 	"log"
 	"os"
-	"sort"
 	"strconv"
 	"strings"
 )
@@ -16,6 +15,12 @@ type node struct {
 }
 
 func Run(fileName string) string {
+	return RunWith(fileName, common.Options{})
+}
+
+// RunWith solves fileName with a single goroutine reading it line by line.
+// Of opts it only uses Partial.
+func RunWith(fileName string, opts common.Options) string {
 	f, err := os.Open(fileName)
 	if err != nil {
 		log.Fatalf("Failed to open the file %v", err)
@@ -33,46 +38,55 @@ func Run(fileName string) string {
 	mapping := make(map[string]*node)
 
 	for s.Scan() {
-		line := s.Text()
-		data := strings.Split(line, ";")
-		key := data[0]
-		val := convertStringToInt64(data[1])
-		if err != nil {
-			log.Fatalf("Failed to parse temp %v", err)
-		}
+		handleLine(s.Text(), mapping)
+	}
+
+	return result(mapping, opts)
+}
 
-		if item, ok := mapping[data[0]]; !ok {
-			mapping[key] = &node{min: val, max: val, sum: val, count: 1}
-		} else {
-			item.max = max(item.max, val)
-			item.min = min(item.min, val)
-			item.count += 1
-			item.sum += val
+// RunSource solves the file read from src one block of opts.ChunkSize bytes
+// at a time. Like Run it uses a single goroutine.
+func RunSource(src common.BlockSource, opts common.Options) string {
+	mapping := make(map[string]*node)
+	err := common.ForEachBlock(src, opts.ChunkSize, 1, func(_ int, lines []byte) {
+		for len(lines) > 0 {
+			end := bytes.IndexByte(lines, '\n')
+			if end < 0 {
+				end = len(lines)
+			}
+			handleLine(string(lines[:end]), mapping)
+			lines = lines[min(end+1, len(lines)):]
 		}
+	})
+	if err != nil {
+		log.Fatalf("Failed to read the file %v", err)
 	}
 
-	cities := make([]string, 0, len(mapping))
-	for city := range mapping {
-		cities = append(cities, city)
-	}
-	sort.Strings(cities)
+	return result(mapping, opts)
+}
 
-	var stringsBuilder strings.Builder
+func handleLine(line string, mapping map[string]*node) {
+	data := strings.Split(line, ";")
+	key := data[0]
+	val := convertStringToInt64(data[1])
 
-	stringsBuilder.WriteString(fmt.Sprintf("{"))
-	for i, city := range cities {
-		if i > 0 {
-			stringsBuilder.WriteString(", ")
-		}
-		m := mapping[city]
-		stringsBuilder.WriteString(fmt.Sprintf("%s=%.1f/%.1f/%.1f", city,
-			common.Round(float64(m.min)/10.0),
-			common.Round(float64(m.sum)/10.0/float64(m.count)),
-			common.Round(float64(m.max)/10.0)))
+	if item, ok := mapping[data[0]]; !ok {
+		mapping[key] = &node{min: val, max: val, sum: val, count: 1}
+	} else {
+		item.max = max(item.max, val)
+		item.min = min(item.min, val)
+		item.count += 1
+		item.sum += val
 	}
-	stringsBuilder.WriteString(fmt.Sprintf("}\n"))
+}
 
-	return stringsBuilder.String()
+// result formats the stations of the mapping.
+func result(mapping map[string]*node, opts common.Options) string {
+	res := make(common.Result, 0, len(mapping))
+	for city, m := range mapping {
+		res = append(res, common.Station{Name: city, Min: m.min, Max: m.max, Sum: m.sum, Count: m.count})
+	}
+	return common.Finish(res, opts)
 }
 
 func convertStringToInt64(input string) int64 {
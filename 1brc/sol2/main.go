@@ -1,13 +1,10 @@
 package sol2
 
 import (
-	"bytes"
-	"fmt"
 	"github.com/draculaas/1brc/common"
+	"log"
 	"runtime"
-	"sort"
-	"strings"
-	"sync"
+	"time"
 )
 
 type node struct {
@@ -15,51 +12,81 @@ type node struct {
 }
 
 func Run(fileName string) string {
-	data := common.Mmap(fileName)
+	return RunWith(fileName, common.Options{})
+}
 
-	workers := runtime.NumCPU()
-	chunkSize := len(data) / workers
-	if chunkSize == 0 {
-		chunkSize = len(data)
+// RunWith solves fileName split into line aligned ranges of opts.ChunkSize
+// bytes, which opts.Workers goroutines steal from each other, see
+// common.ScheduleFile. With opts.Window it maps windows of that many bytes one
+// at a time instead. Zero options are taken from the tuning file of the
+// machine, see common.Tuned.
+func RunWith(fileName string, opts common.Options) string {
+	opts = common.Tuned("sol2", opts)
+	if opts.Window > 0 {
+		src, err := common.OpenWindowSource(fileName, opts.Mmap)
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer src.Close()
+		opts.ChunkSize = opts.Window
+		return RunSource(src, opts)
 	}
+	m := common.Mmap(fileName, opts.Mmap)
+	defer m.Close()
+	data := m.Data
 
-	chunks := make([]int, 0, chunkSize)
-	offset := 0
-
-	for offset < len(data) {
-		offset += chunkSize
+	workers := runtime.NumCPU()
+	if opts.Workers > 0 {
+		workers = opts.Workers
+	}
 
-		if offset >= len(data) {
-			chunks = append(chunks, len(data))
-			break
-		}
-		chunkEndPos := bytes.IndexByte(data[offset:], '\n')
-		if chunkEndPos == -1 {
-			chunks = append(chunks, len(data))
-			break
-		} else {
-			offset += chunkEndPos + 1
-			chunks = append(chunks, offset)
-		}
+	intermediate := make([]map[string]*node, workers)
+	for i := range intermediate {
+		intermediate[i] = make(map[string]*node)
+	}
+	stats := common.ScheduleFile(fileName, data, opts, workers, func(worker, start, end int) {
+		handleChunk(data[start:end], intermediate[worker])
+	})
+	if opts.Report != nil {
+		common.ReportWorkers(opts.Report, "sol2", stats)
 	}
 
-	var wg sync.WaitGroup
-	wg.Add(len(chunks))
+	return result(intermediate, opts)
+}
 
-	intermediate := make([]map[string]*node, len(chunks))
-	start := 0
+// RunSource solves the file read from src, running the parse loop of every
+// worker on blocks of opts.ChunkSize bytes. opts.Report gets the busy time
+// of every worker.
+func RunSource(src common.BlockSource, opts common.Options) string {
+	opts = common.Tuned("sol2", opts)
+	workers := runtime.NumCPU()
+	if opts.Workers > 0 {
+		workers = opts.Workers
+	}
 
-	for i, end := range chunks {
-		dataSlice := data[start:end]
-		go func() {
-			intermediate[i] = handleChunk(dataSlice)
-			wg.Done()
-		}()
-		start = end
+	intermediate := make([]map[string]*node, workers)
+	for i := range intermediate {
+		intermediate[i] = make(map[string]*node)
+	}
+	stats := make([]common.WorkerStats, len(intermediate))
+	err := common.ForEachBlock(src, opts.ChunkSize, workers, func(worker int, lines []byte) {
+		began := time.Now()
+		handleChunk(lines, intermediate[worker])
+		stats[worker].Busy += time.Since(began)
+		stats[worker].Ranges++
+	})
+	if err != nil {
+		log.Fatalf("Failed to read the file %v", err)
+	}
+	if opts.Report != nil {
+		common.ReportWorkers(opts.Report, "sol2", stats)
 	}
 
-	wg.Wait()
+	return result(intermediate, opts)
+}
 
+// result merges the maps of all the workers and formats the result.
+func result(intermediate []map[string]*node, opts common.Options) string {
 	mapping := make(map[string]*node)
 
 	for _, mp := range intermediate {
@@ -75,33 +102,15 @@ func Run(fileName string) string {
 		}
 	}
 
-	cities := make([]string, 0, len(mapping))
-	for city := range mapping {
-		cities = append(cities, city)
+	res := make(common.Result, 0, len(mapping))
+	for city, m := range mapping {
+		res = append(res, common.Station{Name: city, Min: m.min, Max: m.max, Sum: m.sum, Count: m.count})
 	}
-	sort.Strings(cities)
-
-	var stringsBuilder strings.Builder
-
-	stringsBuilder.WriteString(fmt.Sprintf("{"))
-	for i, city := range cities {
-		if i > 0 {
-			stringsBuilder.WriteString(", ")
-		}
-		m := mapping[city]
-		stringsBuilder.WriteString(fmt.Sprintf("%s=%.1f/%.1f/%.1f", city,
-			common.Round(float64(m.min)/10.0),
-			common.Round(float64(m.sum)/10.0/float64(m.count)),
-			common.Round(float64(m.max)/10.0)))
-	}
-	stringsBuilder.WriteString(fmt.Sprintf("}\n"))
-
-	return stringsBuilder.String()
+	return common.Finish(res, opts)
 }
 
-func handleChunk(data []byte) map[string]*node {
+func handleChunk(data []byte, mapping map[string]*node) {
 	pos := 0
-	mapping := make(map[string]*node)
 
 	for len(data) > 0 {
 		for i, b := range data {
@@ -146,6 +155,4 @@ func handleChunk(data []byte) map[string]*node {
 			item.count++
 		}
 	}
-
-	return mapping
 }
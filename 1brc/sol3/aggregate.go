@@ -0,0 +1,146 @@
+package sol3
+
+import (
+	"log"
+	"runtime"
+	"slices"
+	"strings"
+
+	"github.com/draculaas/1brc/agg"
+	"github.com/draculaas/1brc/common"
+)
+
+// aggNode is Node holding any aggregate instead of min/max/sum/count.
+type aggNode[A any] struct {
+	key  string
+	hash Hash
+	next *aggNode[A]
+	agg  A
+}
+
+// aggBucket is Bucket of aggNode.
+type aggBucket[A any, P agg.Aggregator[A]] struct {
+	keys   []string
+	bucket [bucketSize]*aggNode[A]
+}
+
+func (b *aggBucket[A, P]) find(h Hash, key string) *aggNode[A] {
+	cb := b.bucket[h.Index()]
+	for cb != nil {
+		if h == cb.hash && (len(key) <= 8 || key == cb.key) {
+			return cb
+		}
+		cb = cb.next
+	}
+	return nil
+}
+
+// insert returns the node of key, starting a new one with a copy of proto
+// and false if there is none yet.
+func (b *aggBucket[A, P]) insert(h Hash, key []byte, proto *A) (*aggNode[A], bool) {
+	idx := h.Index()
+	cb := b.bucket[idx]
+	prev := cb
+	for cb != nil {
+		if h == cb.hash && (len(key) <= 8 || string(key) == cb.key) {
+			return cb, true
+		}
+		prev = cb
+		cb = cb.next
+	}
+	node := &aggNode[A]{
+		key:  string(key),
+		hash: h,
+		agg:  *proto,
+	}
+
+	if prev != nil {
+		prev.next = node
+	} else {
+		b.bucket[idx] = node
+	}
+	b.keys = append(b.keys, node.key)
+	return node, false
+}
+
+// process aggregates the lines in data[start:end], which starts at off of
+// the file, into the bucket.
+func (b *aggBucket[A, P]) process(data []byte, off int64, start, end uint64, proto *A) {
+	for start < end {
+		pos := off + int64(start)
+		firstBytes := Word(data, start)
+
+		var city []byte
+
+		// check the presence of a semicolon within the initial 8 bytes
+		if idx := FindSemicolon(firstBytes); idx >= 0 {
+			city = data[start : start+uint64(idx)]
+			start += uint64(idx) + 1
+		} else {
+			for i := start + 8; i < end; i += 8 {
+				u := Word(data, i)
+				if idx = FindSemicolon(u); idx >= 0 {
+					city = data[start : i+uint64(idx)]
+					start = i + uint64(idx) + 1
+					break
+				}
+			}
+		}
+		hashKey := MakeHashKey(firstBytes, len(city))
+		u := Word(data, start)
+		temp, adv := parseNumber(u)
+
+		if node, ok := b.insert(hashKey, city, proto); ok {
+			P(&node.agg).Add(int64(temp), pos)
+		} else {
+			P(&node.agg).Init(int64(temp), pos)
+		}
+		start += adv
+	}
+}
+
+// RunAggregate solves the file read from src with the parse loop of sol3,
+// aggregating the values of every station into a copy of proto instead of
+// min/mean/max, and formats each with Finalize.
+func RunAggregate[A any, P agg.Aggregator[A]](src common.BlockSource, opts common.Options, proto A) string {
+	opts = common.Tuned("sol3", opts)
+	numGoroutines := runtime.NumCPU()
+	if opts.Workers > 0 {
+		numGoroutines = opts.Workers
+	}
+
+	maps := make([]*aggBucket[A, P], numGoroutines)
+	for i := range maps {
+		maps[i] = new(aggBucket[A, P])
+	}
+	err := common.ForEachBlockAt(src, opts.ChunkSize, numGoroutines, func(worker int, off int64, lines []byte) {
+		maps[worker].process(lines, off, 0, uint64(len(lines)), &proto)
+	})
+	if err != nil {
+		log.Fatalf("Failed to read the file %v", err)
+	}
+
+	var cities []string
+	for i := range maps {
+		cities = append(cities, maps[i].keys...)
+	}
+	slices.Sort(cities)
+	cities = slices.Compact(cities)
+
+	res := make([]string, 0, len(cities))
+	for _, city := range cities {
+		key := nameKey(city)
+		var a *A
+		for k := range maps {
+			if node := maps[k].find(key, city); node != nil {
+				if a == nil {
+					a = &node.agg
+				} else {
+					P(a).Merge(&node.agg)
+				}
+			}
+		}
+		res = append(res, city+"="+P(a).Finalize())
+	}
+	return "{" + strings.Join(res, ", ") + "}\n"
+}
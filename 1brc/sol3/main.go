@@ -1,15 +1,13 @@
 package sol3
 
 import (
-	"fmt"
 	"github.com/draculaas/1brc/common"
+	"log"
 	"math"
 	"math/bits"
 	"runtime"
 	"slices"
-	"strings"
-	"sync"
-	"unsafe"
+	"time"
 )
 
 const (
@@ -36,85 +34,79 @@ const (
 )
 
 func Run(fileName string) string {
-	numGoroutines := runtime.NumCPU()
-	data := common.Mmap(fileName)
-	chunkSize := len(data) / numGoroutines
-	chunks := make([]int, 0, numGoroutines)
-	start := 0
-
-	for start < len(data) {
-		start += chunkSize
-		if start >= len(data) {
-			chunks = append(chunks, len(data))
-			break
-		}
-		for ; start < len(data); start++ {
-			if data[start] == '\n' {
-				start++
-				break
-			}
-		}
-		if start == len(data) {
-			chunks = append(chunks, len(data))
-			break
+	return RunWith(fileName, common.Options{})
+}
+
+// RunWith solves fileName split into line aligned ranges of opts.ChunkSize
+// bytes, which opts.Workers goroutines steal from each other, see
+// common.ScheduleFile. With opts.Window it maps windows of that many bytes one
+// at a time instead. Zero options are taken from the tuning file of the
+// machine, see common.Tuned.
+func RunWith(fileName string, opts common.Options) string {
+	opts = common.Tuned("sol3", opts)
+	if opts.Window > 0 {
+		src, err := common.OpenWindowSource(fileName, opts.Mmap)
+		if err != nil {
+			log.Fatal(err)
 		}
-		chunks = append(chunks, start)
+		defer src.Close()
+		opts.ChunkSize = opts.Window
+		return RunSource(src, opts)
+	}
+	numGoroutines := runtime.NumCPU()
+	if opts.Workers > 0 {
+		numGoroutines = opts.Workers
+	}
+	m := common.Mmap(fileName, opts.Mmap)
+	defer m.Close()
+	data := m.Data
+	maps := make([]*Bucket, numGoroutines)
+	for i := range maps {
+		maps[i] = new(Bucket)
+	}
+	stats := common.ScheduleFile(fileName, data, opts, numGoroutines, func(worker, start, end int) {
+		maps[worker].process(data, uint64(start), uint64(end))
+	})
+	if opts.Report != nil {
+		common.ReportWorkers(opts.Report, "sol3", stats)
 	}
 
-	start = 0
-	var wg sync.WaitGroup
-	maps := make([]*Bucket, len(chunks))
-
-	for i, end := range chunks {
-		wg.Add(1)
-
-		go func(workerId int, start, end uint64) {
-			defer wg.Done()
-			var b Bucket
-			for start < end {
-				firstBytes := *(*uint64)(unsafe.Pointer(&data[start]))
-
-				// fmt.Printf("workerId: %d, partition: %v -> %v, semi: %v\n", workerId, start, end, FindSemicolon(firstBytes))
-
-				var city []byte
-
-				// check the presence of a semicolon within the initial 8 bytes
-				if idx := FindSemicolon(firstBytes); idx >= 0 {
-					city = data[start : start+uint64(idx)]
-					start += uint64(idx) + 1
-				} else {
-					// presence of the a semicolon within the first 8 bytes not found
-					// move the pointer and check the next 8 bytes
-					for i := start + 8; i < end; i += 8 {
-						u := *(*uint64)(unsafe.Pointer(&data[i]))
-						if idx = FindSemicolon(u); idx >= 0 {
-							city = data[start : i+uint64(idx)]
-							start = i + uint64(idx) + 1
-							break
-						}
-					}
-				}
-				// generate a hash using the current city name
-				hashKey := MakeHashKey(firstBytes, len(city))
-				// parse the number
-				u := *(*uint64)(unsafe.Pointer(&data[start]))
-				temp, adv := parseNumber(u)
-
-				node := b.Insert(hashKey, city)
-				node.min = min(node.min, temp)
-				node.max = max(node.max, temp)
-				node.sum += int64(temp)
-				node.count++
-				maps[workerId] = &b
-				// move start pointer
-				start += adv
-			}
-		}(i, uint64(start), uint64(end))
-		start = end
+	return result(maps, opts)
+}
+
+// RunSource solves the file read from src, running the parse loop of every
+// worker on blocks of opts.ChunkSize bytes. opts.Report gets the busy time
+// of every worker.
+func RunSource(src common.BlockSource, opts common.Options) string {
+	opts = common.Tuned("sol3", opts)
+	numGoroutines := runtime.NumCPU()
+	if opts.Workers > 0 {
+		numGoroutines = opts.Workers
+	}
+
+	maps := make([]*Bucket, numGoroutines)
+	for i := range maps {
+		maps[i] = new(Bucket)
+	}
+	stats := make([]common.WorkerStats, len(maps))
+	err := common.ForEachBlock(src, opts.ChunkSize, numGoroutines, func(worker int, lines []byte) {
+		began := time.Now()
+		maps[worker].process(lines, 0, uint64(len(lines)))
+		stats[worker].Busy += time.Since(began)
+		stats[worker].Ranges++
+	})
+	if err != nil {
+		log.Fatalf("Failed to read the file %v", err)
+	}
+	if opts.Report != nil {
+		common.ReportWorkers(opts.Report, "sol3", stats)
 	}
 
-	wg.Wait()
+	return result(maps, opts)
+}
 
+// result merges the buckets of all the workers and formats the result.
+func result(maps []*Bucket, opts common.Options) string {
 	// get the total number of cities
 	totalCities := 0
 	for i := range maps {
@@ -133,18 +125,15 @@ func Run(fileName string) string {
 	slices.Sort(cities)
 	cities = slices.Compact(cities)
 
-	var stringsBuilder strings.Builder
-	stringsBuilder.WriteString(fmt.Sprintf("{"))
-
-	for i, city := range cities {
+	res := make(common.Result, 0, len(cities))
+	for _, city := range cities {
 		n := Node{
 			key: city,
 			min: math.MaxInt16,
 			max: math.MinInt16,
 		}
 
-		u := *(*uint64)(unsafe.Pointer(unsafe.StringData(city)))
-		key := MakeHashKey(u, len(city))
+		key := nameKey(city)
 
 		for k := range maps {
 			if item := maps[k].Find(key, city); item != nil {
@@ -155,19 +144,49 @@ func Run(fileName string) string {
 			}
 		}
 
-		if i > 0 {
-			stringsBuilder.WriteString(", ")
-		}
-
-		stringsBuilder.WriteString(fmt.Sprintf("%s=%.1f/%.1f/%.1f", city,
-			common.Round(float64(n.min)/10.0),
-			common.Round(float64(n.sum)/10.0/float64(n.count)),
-			common.Round(float64(n.max)/10.0)))
+		res = append(res, common.Station{Name: city, Min: int64(n.min), Max: int64(n.max), Sum: n.sum, Count: n.count})
 	}
 
-	stringsBuilder.WriteString(fmt.Sprintf("}\n"))
+	return common.Finish(res, opts)
+}
 
-	return stringsBuilder.String()
+// process aggregates the lines in data[start:end] into the bucket.
+func (b *Bucket) process(data []byte, start, end uint64) {
+	for start < end {
+		firstBytes := Word(data, start)
+
+		var city []byte
+
+		// check the presence of a semicolon within the initial 8 bytes
+		if idx := FindSemicolon(firstBytes); idx >= 0 {
+			city = data[start : start+uint64(idx)]
+			start += uint64(idx) + 1
+		} else {
+			// presence of the a semicolon within the first 8 bytes not found
+			// move the pointer and check the next 8 bytes
+			for i := start + 8; i < end; i += 8 {
+				u := Word(data, i)
+				if idx = FindSemicolon(u); idx >= 0 {
+					city = data[start : i+uint64(idx)]
+					start = i + uint64(idx) + 1
+					break
+				}
+			}
+		}
+		// generate a hash using the current city name
+		hashKey := MakeHashKey(firstBytes, len(city))
+		// parse the number
+		u := Word(data, start)
+		temp, adv := parseNumber(u)
+
+		node := b.Insert(hashKey, city)
+		node.min = min(node.min, temp)
+		node.max = max(node.max, temp)
+		node.sum += int64(temp)
+		node.count++
+		// move start pointer
+		start += adv
+	}
 }
 
 func FindSemicolon(word uint64) int {
@@ -0,0 +1,21 @@
+package sol3
+
+import "encoding/binary"
+
+// PortableWord returns the 8 bytes of data at i as a little-endian word,
+// reading zeros past the end of data. It is the portable version of Word.
+func PortableWord(data []byte, i uint64) uint64 {
+	if uint64(len(data))-i >= 8 {
+		return binary.LittleEndian.Uint64(data[i:])
+	}
+	var tail [8]byte
+	copy(tail[:], data[i:])
+	return binary.LittleEndian.Uint64(tail[:])
+}
+
+// nameKey returns the hash key process computes for the station name.
+func nameKey(name string) Hash {
+	var head [8]byte
+	copy(head[:], name)
+	return MakeHashKey(binary.LittleEndian.Uint64(head[:]), len(name))
+}
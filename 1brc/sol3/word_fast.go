@@ -0,0 +1,12 @@
+//go:build !purego
+
+package sol3
+
+import "unsafe"
+
+// Word returns the 8 bytes of data at i as a word with a single unaligned
+// load, assuming a little-endian machine. Up to 7 bytes may come from past
+// the end of data, which the sources keep readable, see common.Padding.
+func Word(data []byte, i uint64) uint64 {
+	return *(*uint64)(unsafe.Pointer(&data[i]))
+}
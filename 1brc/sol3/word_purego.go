@@ -0,0 +1,9 @@
+//go:build purego
+
+package sol3
+
+// Word returns the 8 bytes of data at i as a little-endian word, see
+// PortableWord.
+func Word(data []byte, i uint64) uint64 {
+	return PortableWord(data, i)
+}
@@ -0,0 +1,100 @@
+package sol4
+
+import (
+	"runtime"
+	"sort"
+	"strings"
+
+	"github.com/draculaas/1brc/agg"
+	"github.com/draculaas/1brc/common"
+)
+
+// aggTable is the table of sol4 holding any aggregate. Slots index into
+// dense slices, so a large aggregate does not blow up the table.
+type aggTable[A any, P agg.Aggregator[A]] struct {
+	hashes [bucketSize]uint64
+	index  [bucketSize]int32
+	names  []string
+	aggs   []A
+}
+
+func (t *aggTable[A, P]) find(hash uint64) (bool, uint64) {
+	for i := hash % bucketSize; ; i = (i + 1) % bucketSize {
+		if t.hashes[i] == hash {
+			return true, i
+		}
+		if t.hashes[i] == 0 {
+			return false, i
+		}
+	}
+}
+
+func (t *aggTable[A, P]) process(b []byte, off int64, proto *A) {
+	for start := uintptr(0); start < uintptr(len(b)); {
+		hash, val, nameLen, lineLen := Parse(b, start)
+		pos := off + int64(start)
+		if ok, i := t.find(hash); ok {
+			P(&t.aggs[t.index[i]]).Add(val, pos)
+		} else {
+			t.hashes[i] = hash
+			t.index[i] = int32(len(t.aggs))
+			t.names = append(t.names, string(b[start:start+nameLen]))
+			t.aggs = append(t.aggs, *proto)
+			P(&t.aggs[len(t.aggs)-1]).Init(val, pos)
+		}
+		start += lineLen
+	}
+}
+
+// RunAggregate solves the file read from src with the parse loop of sol4,
+// aggregating the values of every station into a copy of proto instead of
+// min/mean/max, and formats each with Finalize.
+func RunAggregate[A any, P agg.Aggregator[A]](src common.BlockSource, opts common.Options, proto A) string {
+	opts = common.Tuned("sol4", opts)
+	numGoroutines := runtime.GOMAXPROCS(0)
+	if opts.Workers > 0 {
+		numGoroutines = opts.Workers
+	}
+
+	tables := make([]*aggTable[A, P], numGoroutines)
+	for i := range tables {
+		tables[i] = new(aggTable[A, P])
+	}
+	err := common.ForEachBlockAt(src, opts.ChunkSize, numGoroutines, func(i int, off int64, lines []byte) {
+		tables[i].process(lines, off, &proto)
+	})
+	if err != nil {
+		panic(err)
+	}
+
+	t := tables[0]
+	for _, o := range tables[1:] {
+		for i, hash := range o.hashes {
+			if hash == 0 {
+				continue
+			}
+			x := &o.aggs[o.index[i]]
+			if ok, j := t.find(hash); ok {
+				P(&t.aggs[t.index[j]]).Merge(x)
+			} else {
+				t.hashes[j] = hash
+				t.index[j] = int32(len(t.aggs))
+				t.names = append(t.names, o.names[o.index[i]])
+				t.aggs = append(t.aggs, *x)
+			}
+		}
+	}
+
+	order := make([]int, len(t.names))
+	for i := range order {
+		order[i] = i
+	}
+	sort.Slice(order, func(i, j int) bool {
+		return t.names[order[i]] < t.names[order[j]]
+	})
+	res := make([]string, 0, len(order))
+	for _, i := range order {
+		res = append(res, t.names[i]+"="+P(&t.aggs[i]).Finalize())
+	}
+	return "{" + strings.Join(res, ", ") + "}\n"
+}
@@ -0,0 +1,123 @@
+package sol4
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"log"
+	"os"
+
+	"github.com/draculaas/1brc/mph"
+)
+
+// dictionary indexes the stations known ahead of time with a minimal perfect
+// hash of their hashes, so a known station takes one lookup instead of
+// probing.
+type dictionary struct {
+	table  *mph.Table
+	hashes []uint64
+}
+
+// loadDictionary reads one station name per line of fileName, ignoring
+// anything after a ';', empty lines and lines starting with '#', like in
+// data/weather_stations.csv. A file without any name is an error.
+func loadDictionary(fileName string) (*dictionary, error) {
+	f, err := os.Open(fileName)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	seen := make(map[uint64]bool)
+	var hashes []uint64
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		line := sc.Bytes()
+		if len(line) == 0 || line[0] == '#' {
+			continue
+		}
+		if i := bytes.IndexByte(line, ';'); i >= 0 {
+			line = line[:i]
+		}
+		// names with the same hash are one station to sol4 anyway
+		if hash := HashName(line); !seen[hash] {
+			seen[hash] = true
+			hashes = append(hashes, hash)
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+	if len(hashes) == 0 {
+		return nil, fmt.Errorf("dictionary %s: no station names", fileName)
+	}
+
+	table, err := mph.Build(hashes)
+	if err != nil {
+		return nil, err
+	}
+	d := &dictionary{table: table, hashes: make([]uint64, len(hashes))}
+	for _, hash := range hashes {
+		d.hashes[table.Index(hash)] = hash
+	}
+	return d, nil
+}
+
+// openDictionary loads the dictionary fileName, if any.
+func openDictionary(fileName string) *dictionary {
+	if fileName == "" {
+		return nil
+	}
+	d, err := loadDictionary(fileName)
+	if err != nil {
+		log.Fatal(err)
+	}
+	return d
+}
+
+// report writes how many of the stations found were in the dictionary.
+func (d *dictionary) report(w io.Writer, records []record) {
+	unknown := 0
+	for i := range records {
+		if _, ok := d.index(records[i].hash); !ok {
+			unknown++
+		}
+	}
+	fmt.Fprintf(w, "sol4 dictionary of %d stations: %d found, %d unknown\n", len(d.hashes), len(records)-unknown, unknown)
+}
+
+// index returns the slot of hash, or false for a station not in the
+// dictionary.
+func (d *dictionary) index(hash uint64) (uint64, bool) {
+	i := d.table.Index(hash)
+	return i, d.hashes[i] == hash
+}
+
+// processKnown is process looking the stations up in the dictionary first,
+// falling back to the general table for unknown ones.
+func (w *worker) processKnown(b []byte) {
+	for start := uintptr(0); start < uintptr(len(b)); {
+		hash, val, nameLen, lineLen := Parse(b, start)
+		var item *record
+		if i, ok := w.dict.index(hash); ok {
+			item = &w.known[i]
+		} else {
+			_, item = w.m.find(hash)
+		}
+		if item.hash == 0 {
+			item.hash = hash
+			item.name = string(b[start : start+nameLen])
+			item.count = 1
+			item.min = val
+			item.max = val
+			item.sum = val
+		} else {
+			item.min = min(item.min, val)
+			item.max = max(item.max, val)
+			item.sum += val
+			item.count++
+		}
+		start += lineLen
+	}
+}
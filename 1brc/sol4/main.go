@@ -2,26 +2,46 @@ package sol4
 
 import (
 	"bytes"
-	"fmt"
+	"encoding/binary"
 	"github.com/draculaas/1brc/common"
-	"math/bits"
 	"os"
 	"runtime"
 	"sort"
-	"strings"
 	"sync"
-	"unsafe"
+	"time"
 )
 
-var file *os.File
-
 const (
-	chunkSize  = 1 << 20
-	bucketSize = 1 << 16
+	defaultChunkSize = 1 << 20
+	// every block must hold a newline for the boundary stitching to work,
+	// lines are at most 107 bytes long
+	minChunkSize = 1 << 12
+	bucketSize   = 1 << 16
 )
 
-type split struct {
-	offset, len int64
+// Split is a byte range of a file, lines are not aligned to it.
+type Split struct {
+	Offset, Len int64
+}
+
+// indexSplits returns the splits between the line aligned bounds of an
+// index, see common.Index.Bounds.
+func indexSplits(bounds []int64) []Split {
+	splits := make([]Split, 0, max(len(bounds)-1, 0))
+	for i := 1; i < len(bounds); i++ {
+		splits = append(splits, Split{Offset: bounds[i-1], Len: bounds[i] - bounds[i-1]})
+	}
+	return splits
+}
+
+// Splits cuts a file of size bytes into splits of chunkSize bytes, the last
+// one shorter.
+func Splits(size, chunkSize int64) []Split {
+	splits := make([]Split, 0, (size+chunkSize-1)/chunkSize)
+	for offset := int64(0); offset < size; offset += chunkSize {
+		splits = append(splits, Split{Offset: offset, Len: min(chunkSize, size-offset)})
+	}
+	return splits
 }
 
 type chunk struct {
@@ -33,111 +53,107 @@ type chunk struct {
 type worker struct {
 	m      mapping
 	chunks []chunk
+	// dict, when set, indexes known, the records of the dictionary
+	// stations
+	dict  *dictionary
+	known []record
 }
 
-func (w *worker) exec(wg *sync.WaitGroup, ch <-chan split) {
-	buf := make([]byte, chunkSize)
+func newWorker(dict *dictionary) *worker {
+	w := &worker{dict: dict}
+	if dict != nil {
+		w.known = make([]record, len(dict.hashes))
+	}
+	return w
+}
+
+// exec aggregates the splits read from ch, keeping the pieces of the lines
+// they cut for stitch. Aligned splits start at a line, so only the end of a
+// file without a final newline is left to stitch.
+func (w *worker) exec(wg *sync.WaitGroup, ch <-chan Split, file *os.File, chunkSize int64, aligned bool) {
+	// parse loads the word behind the last line of a chunk
+	buf := make([]byte, chunkSize+common.Padding)
 	chunks := make([]chunk, 0, 100)
 
 	for r := range ch {
-		b := buf[0:r.len]
-		_, err := file.ReadAt(b, r.offset)
+		b := buf[0:r.Len]
+		_, err := file.ReadAt(b, r.Offset)
 		if err != nil {
 			return
 		}
 
-		firstEndLine := bytes.IndexByte(b, '\n')
-		chunks = append(chunks, chunk{
-			offset: r.offset,
-			start:  false,
-			raw:    string(b[:firstEndLine+1]),
-		})
+		firstEndLine := -1
+		if !aligned {
+			firstEndLine = bytes.IndexByte(b, '\n')
+			chunks = append(chunks, chunk{
+				offset: r.Offset,
+				start:  false,
+				raw:    string(b[:firstEndLine+1]),
+			})
+		}
 
 		lastEndLine := bytes.LastIndexByte(b, '\n')
 		if lastEndLine < len(b)-1 {
 			chunks = append(chunks, chunk{
-				offset: r.offset + r.len,
+				offset: r.Offset + r.Len,
 				start:  true,
 				raw:    string(b[lastEndLine+1:]),
 			})
 		}
 
-		startPtr := uintptr(unsafe.Pointer(&b[0]))
-		start := startPtr + uintptr(firstEndLine) + 1
-		end := startPtr + uintptr(lastEndLine) + 1
-
-		for start < end {
-			hash, val, nameLen, lineLen := parse(start)
-			// find item in map
-			ok, item := w.m.find(hash)
-			if !ok {
-				item.hash = hash
-				item.name = string(b[start-startPtr : start-startPtr+nameLen])
-				item.count = 1
-				item.min = val
-				item.max = val
-				item.sum = val
-			} else {
-				item.min = min(item.min, val)
-				item.max = max(item.max, val)
-				item.sum += val
-				item.count++
-			}
-			start += lineLen
-		}
+		w.process(b[firstEndLine+1 : lastEndLine+1])
 	}
 
 	w.chunks = chunks
 	wg.Done()
 }
 
-func parse(ptr uintptr) (hash uint64, val int64, nameLen, lineLen uintptr) {
-	sep := ptr + 1
-	for ; *(*byte)(unsafe.Pointer(sep)) != ';'; sep++ {
+// process aggregates the complete lines in b.
+func (w *worker) process(b []byte) {
+	if w.dict != nil {
+		w.processKnown(b)
+		return
 	}
-	nameLen = sep - ptr
+	if len(b) == 0 {
+		return
+	}
+	for start := uintptr(0); start < uintptr(len(b)); {
+		hash, val, nameLen, lineLen := Parse(b, start)
+		// find item in map
+		ok, item := w.m.find(hash)
+		if !ok {
+			item.hash = hash
+			item.name = string(b[start : start+nameLen])
+			item.count = 1
+			item.min = val
+			item.max = val
+			item.sum = val
+		} else {
+			item.min = min(item.min, val)
+			item.max = max(item.max, val)
+			item.sum += val
+			item.count++
+		}
+		start += lineLen
+	}
+}
 
-	for ; ptr+8 < sep; ptr += 8 {
-		hash ^= *(*uint64)(unsafe.Pointer(ptr))
+// HashName returns the hash parse computes for the station name.
+func HashName(name []byte) uint64 {
+	var hash uint64
+	for ; len(name) > 8; name = name[8:] {
+		hash ^= binary.LittleEndian.Uint64(name)
 		hash *= 7
 	}
-	hash ^= *(*uint64)(unsafe.Pointer(ptr)) & ((1 << ((sep - ptr) * 8)) - 1)
-
-	// Let's try to parse without any conditionals.
-	//
-	// Four possibilities:
-	//
-	//   a.b\n         ?? ?? 0A bb 2E aa
-	//   ab.c\n        ?? 0A cc 2E bb aa
-	//   -a.b\n        ?? 0A bb 2E aa 2D
-	//   -ab.c\n       0A cc 2E bb aa 2D
-
-	// ASCII values:
-	//  -    0x2D      0b00101101
-	//  .    0x2E      0b00101110
-	//  \n   0x0A      0b00001010
-	//  0-9  0x30-0x39 0b0011....
-
-	// Restrict to the lower 5 bytes.
-	x := *(*uint64)(unsafe.Pointer(sep + 1)) & 0xFFFFFFFFFF
-
-	// Digits have the 5th bit (0x10) set to 1. The decimal point
-	// can be in byte 1 (0x1000), 2 (0x100000) or 3 (0x10000000).
-	n := bits.TrailingZeros64((^x) & 0x10101000)
-	// Byte 1: n=12, format is "a.b"
-	// Byte 2: n=20, format is "ab.c" or "-a.b"
-	// Byte 3: n=28, format is "-ab.c"
-
-	// Byte 0 is either a digit or '-'. Again we can check the 5th bit.
-	minus := ((^x) >> 4) & 1        // 0 if no minus, or 1 if minus.
-	minusMask := (minus - 1) & 0xFF // 0xFF if no minus, or 0 of minus.
-	x = (x & (0xFFFFFFFF00 | minusMask)) << (28 - n)
-	valUnsigned := ((x>>8)&0x0F)*100 + ((x>>16)&0x0F)*10 + (x>>32)&0x0F
-	val = int64(valUnsigned ^ (-minus) + minus)
-
-	return hash, val, nameLen, nameLen + 4 + uintptr(n)>>3
+	var tail [8]byte
+	copy(tail[:], name)
+	return hash ^ binary.LittleEndian.Uint64(tail[:])
 }
 
+// Buckets is the number of slots of the table, probing for a hash starts at
+// slot hash % Buckets.
+const Buckets = bucketSize
+
 type record struct {
 	name                 string
 	hash                 uint64
@@ -160,37 +176,65 @@ func (m *mapping) find(hash uint64) (bool, *record) {
 }
 
 func Run(fileName string) string {
-	var err error
-	file, err = os.Open(fileName)
-	defer file.Close()
+	return RunWith(fileName, common.Options{})
+}
+
+// RunWith solves fileName reading it in blocks of opts.ChunkSize bytes on
+// opts.Workers goroutines, looking the stations of opts.Dictionary up with a
+// perfect hash. With an index of the file of a step up to the chunk size,
+// see common.OpenIndex, the blocks start at its line aligned offsets and no
+// line needs stitching. Zero options are taken from the tuning file of the
+// machine, see common.Tuned.
+func RunWith(fileName string, opts common.Options) string {
+	opts = common.Tuned("sol4", opts)
+	chunkSize := int64(defaultChunkSize)
+	if opts.ChunkSize > 0 {
+		chunkSize = int64(max(opts.ChunkSize, minChunkSize))
+	}
+
+	file, err := os.Open(fileName)
 	if err != nil {
 		panic(err)
 	}
+	defer file.Close()
 	info, err := file.Stat()
 	if err != nil {
 		panic(err)
 	}
 	size := info.Size()
 
-	n := int((size + chunkSize - 1) / chunkSize)
-	ch := make(chan split, n)
-
-	for offset := int64(0); offset < size; offset += chunkSize {
-		ch <- split{offset: offset, len: min(chunkSize, size-offset)}
+	// an index with a larger step would make coarser splits and larger
+	// buffers, the splits are then cut at any byte and stitched instead
+	splits, aligned := Splits(size, chunkSize), false
+	if ix := common.OpenIndex(fileName, opts); ix != nil && ix.Size == size && ix.Step <= chunkSize {
+		splits, aligned = indexSplits(ix.Bounds(chunkSize)), true
+		for _, s := range splits {
+			chunkSize = max(chunkSize, s.Len)
+		}
+	}
+	ch := make(chan Split, len(splits))
+	for _, s := range splits {
+		ch <- s
 	}
 	close(ch)
 	numGoroutines := runtime.GOMAXPROCS(0)
+	if opts.Workers > 0 {
+		numGoroutines = opts.Workers
+	}
+	dict := openDictionary(opts.Dictionary)
 
 	// run workers
+	began := time.Now()
 	var wg sync.WaitGroup
 	wg.Add(numGoroutines)
 	workers := make([]*worker, numGoroutines)
 
 	for i := 0; i < numGoroutines; i++ {
-		workers[i] = new(worker)
-		go workers[i].exec(&wg, ch)
+		workers[i] = newWorker(dict)
+		go workers[i].exec(&wg, ch, file, chunkSize, aligned)
 	}
 	wg.Wait()
+	parsed := time.Now()
 
 	// merge all chunks
 	var chunks []chunk
@@ -203,76 +247,69 @@ func Run(fileName string) string {
 			(chunks[i].offset == chunks[j].offset && chunks[i].start && !chunks[j].start)
 	})
 
-	buf := make([]byte, 1024)
-	ptr := uintptr(unsafe.Pointer(&buf[0]))
-
-	for i := 0; i < len(chunks); i++ {
-		buf = append(buf[:0], []byte(chunks[i].raw)...)
-		if i+1 < len(chunks) && chunks[i+1].offset == chunks[i].offset {
-			i++
-			buf = append(buf, []byte(chunks[i].raw)...)
+	records := merge(workers, stitch(chunks, numGoroutines), numGoroutines, dict)
+	if opts.Report != nil {
+		reportPhases(opts.Report, began, parsed)
+		if dict != nil {
+			dict.report(opts.Report, records)
 		}
+	}
+	return result(records, opts)
+}
 
-		hash, val, nameLen, _ := parse(ptr)
+// RunSource solves the file read from src, running the parse loop of every
+// worker on blocks of opts.ChunkSize bytes. The blocks hold complete lines,
+// so there is nothing to stitch.
+func RunSource(src common.BlockSource, opts common.Options) string {
+	return common.Finish(AggregateRange(src, 0, src.Size(), opts), opts)
+}
 
-		ok, item := workers[0].m.find(hash)
-		if !ok {
-			item.hash = hash
-			item.name = string(buf[:nameLen])
-			item.count = 1
-			item.min = val
-			item.max = val
-			item.sum = val
-		} else {
-			item.count++
-			item.min = min(item.min, val)
-			item.max = max(item.max, val)
-			item.sum += val
-		}
+// AggregateRange aggregates the lines starting in the n bytes at off of the
+// file read from src like RunSource, see common.ForEachBlockIn. Ranges
+// covering a file aggregate to the stations of the whole file once merged.
+func AggregateRange(src common.BlockSource, off, n int64, opts common.Options) common.Result {
+	opts = common.Tuned("sol4", opts)
+	numGoroutines := runtime.GOMAXPROCS(0)
+	if opts.Workers > 0 {
+		numGoroutines = opts.Workers
 	}
+	dict := openDictionary(opts.Dictionary)
 
-	for _, w := range workers {
-		for _, x := range w.m.bucket {
-			if x.hash != 0 {
-				ok, xx := workers[0].m.find(x.hash)
-				if !ok {
-					*xx = x
-				} else {
-					xx.sum += x.sum
-					xx.count += x.count
-					xx.min = min(xx.min, x.min)
-					xx.max = max(xx.max, x.max)
-				}
-			}
-		}
+	workers := make([]*worker, numGoroutines)
+	for i := range workers {
+		workers[i] = newWorker(dict)
 	}
-
-	type stats struct {
-		name, min, avg, max string
+	began := time.Now()
+	err := common.ForEachBlockIn(src, off, n, opts.ChunkSize, numGoroutines, func(i int, _ int64, lines []byte) {
+		workers[i].process(lines)
+	})
+	if err != nil {
+		panic(err)
 	}
+	parsed := time.Now()
 
-	ss := make([]stats, 0, 1024)
-
-	for _, item := range workers[0].m.bucket {
-		if item.hash != 0 {
-			ss = append(ss, stats{
-				name: item.name,
-				min:  fmt.Sprintf("%.1f", common.Round(float64(item.min)/10.0)),
-				avg:  fmt.Sprintf("%.1f", common.Round(float64(item.sum)/10.0/float64(item.count))),
-				max:  fmt.Sprintf("%.1f", common.Round(float64(item.max)/10.0)),
-			})
+	records := merge(workers, nil, numGoroutines, dict)
+	if opts.Report != nil {
+		reportPhases(opts.Report, began, parsed)
+		if dict != nil {
+			dict.report(opts.Report, records)
 		}
 	}
+	res := stations(records)
+	res.Sort()
+	return res
+}
 
-	sort.Slice(ss, func(i, j int) bool {
-		return ss[i].name < ss[j].name
-	})
-
-	res := make([]string, 0, len(ss))
+// result formats the merged records.
+func result(records []record, opts common.Options) string {
+	return common.Finish(stations(records), opts)
+}
 
-	for _, i := range ss {
-		res = append(res, fmt.Sprintf("%s=%s/%s/%s", i.name, i.min, i.avg, i.max))
+// stations returns the merged records as the stations of a result.
+func stations(records []record) common.Result {
+	res := make(common.Result, 0, len(records))
+	for _, r := range records {
+		res = append(res, common.Station{Name: r.name, Min: r.min, Max: r.max, Sum: r.sum, Count: r.count})
 	}
-
-	return "{" + strings.Join(res, ", ") + "}\n"
+	return res
 }
@@ -0,0 +1,138 @@
+package sol4
+
+import (
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/draculaas/1brc/common"
+)
+
+// stitch joins the pieces of the lines split between two chunks and parses
+// them on shards goroutines, returning one record per line.
+func stitch(chunks []chunk, shards int) []record {
+	var lines [][]byte
+	for i := 0; i < len(chunks); i++ {
+		line := make([]byte, 0, 2*common.MaxLineLen+common.Padding)
+		line = append(line, chunks[i].raw...)
+		if i+1 < len(chunks) && chunks[i+1].offset == chunks[i].offset {
+			i++
+			line = append(line, chunks[i].raw...)
+		}
+		lines = append(lines, line)
+	}
+
+	records := make([]record, len(lines))
+	var wg sync.WaitGroup
+	for s := 0; s < shards; s++ {
+		wg.Add(1)
+		go func(lines [][]byte, records []record) {
+			defer wg.Done()
+			for i, line := range lines {
+				hash, val, nameLen, _ := Parse(line, 0)
+				records[i] = record{
+					name:  string(line[:nameLen]),
+					hash:  hash,
+					min:   val,
+					max:   val,
+					sum:   val,
+					count: 1,
+				}
+			}
+		}(lines[s*len(lines)/shards:(s+1)*len(lines)/shards], records[s*len(records)/shards:(s+1)*len(records)/shards])
+	}
+	wg.Wait()
+	return records
+}
+
+// merge combines the tables of all the workers and the stitched lines on
+// shards goroutines. Every goroutine owns the stations whose probing starts
+// in its range of slots, so it only scans that range of every table, plus
+// the runs of records probed past its end, and the dictionary stations in
+// its range of dict.
+func merge(workers []*worker, stitched []record, shards int, dict *dictionary) []record {
+	out := make([][]record, shards)
+	var wg sync.WaitGroup
+	for s := 0; s < shards; s++ {
+		wg.Add(1)
+		go func(s int) {
+			defer wg.Done()
+			lo, hi := uint64(s)*bucketSize/uint64(shards), uint64(s+1)*bucketSize/uint64(shards)
+			var known, knownLo, knownHi uint64
+			if dict != nil {
+				known = uint64(len(dict.hashes))
+				knownLo, knownHi = uint64(s)*known/uint64(shards), uint64(s+1)*known/uint64(shards)
+			}
+			owned := func(x *record) bool {
+				home := x.hash % bucketSize
+				return x.hash != 0 && home >= lo && home < hi
+			}
+			ownedStitched := func(x *record) bool {
+				if dict != nil {
+					if i, ok := dict.index(x.hash); ok {
+						return i >= knownLo && i < knownHi
+					}
+				}
+				return owned(x)
+			}
+
+			index := make(map[uint64]int)
+			var records []record
+			add := func(x *record) {
+				i, ok := index[x.hash]
+				if !ok {
+					index[x.hash] = len(records)
+					records = append(records, *x)
+					return
+				}
+				r := &records[i]
+				r.sum += x.sum
+				r.count += x.count
+				r.min = min(r.min, x.min)
+				r.max = max(r.max, x.max)
+			}
+
+			for _, w := range workers {
+				// linear probing keeps every record in the run of used
+				// slots starting at its home slot
+				for i := lo; i < lo+bucketSize; i++ {
+					x := &w.m.bucket[i%bucketSize]
+					if i >= hi && x.hash == 0 {
+						break
+					}
+					if owned(x) {
+						add(x)
+					}
+				}
+			}
+			for _, w := range workers {
+				for i := knownLo; i < knownHi; i++ {
+					if w.known[i].hash != 0 {
+						add(&w.known[i])
+					}
+				}
+			}
+			for i := range stitched {
+				if ownedStitched(&stitched[i]) {
+					add(&stitched[i])
+				}
+			}
+			out[s] = records
+		}(s)
+	}
+	wg.Wait()
+
+	var records []record
+	for _, r := range out {
+		records = append(records, r...)
+	}
+	return records
+}
+
+// reportPhases writes the time spent parsing, from began to parsed, and
+// merging since then.
+func reportPhases(w io.Writer, began, parsed time.Time) {
+	fmt.Fprintf(w, "sol4 parse %v, merge %v\n",
+		parsed.Sub(began).Round(time.Microsecond), time.Since(parsed).Round(time.Microsecond))
+}
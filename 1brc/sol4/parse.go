@@ -0,0 +1,58 @@
+package sol4
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math/bits"
+)
+
+// ParsePortable is the portable version of Parse: it finds the separator
+// with bytes.IndexByte, hashes the name with HashName and loads the
+// temperature with encoding/binary, reading zeros past the end of b.
+func ParsePortable(b []byte, i uintptr) (hash uint64, val int64, nameLen, lineLen uintptr) {
+	line := b[i:]
+	nameLen = uintptr(bytes.IndexByte(line[1:], ';') + 1)
+	hash = HashName(line[:nameLen])
+
+	var word [8]byte
+	copy(word[:5], line[nameLen+1:])
+	val, n := temperature(binary.LittleEndian.Uint64(word[:]))
+	return hash, val, nameLen, nameLen + 4 + n
+}
+
+// temperature decodes the temperature in the lower 5 bytes of the
+// little-endian word x and returns it with the number of bytes it takes
+// beyond the shortest format.
+func temperature(x uint64) (val int64, n uintptr) {
+	// Let's try to parse without any conditionals.
+	//
+	// Four possibilities:
+	//
+	//   a.b\n         ?? ?? 0A bb 2E aa
+	//   ab.c\n        ?? 0A cc 2E bb aa
+	//   -a.b\n        ?? 0A bb 2E aa 2D
+	//   -ab.c\n       0A cc 2E bb aa 2D
+
+	// ASCII values:
+	//  -    0x2D      0b00101101
+	//  .    0x2E      0b00101110
+	//  \n   0x0A      0b00001010
+	//  0-9  0x30-0x39 0b0011....
+
+	// Restrict to the lower 5 bytes.
+	x &= 0xFFFFFFFFFF
+
+	// Digits have the 5th bit (0x10) set to 1. The decimal point
+	// can be in byte 1 (0x1000), 2 (0x100000) or 3 (0x10000000).
+	dot := bits.TrailingZeros64((^x) & 0x10101000)
+	// Byte 1: dot=12, format is "a.b"
+	// Byte 2: dot=20, format is "ab.c" or "-a.b"
+	// Byte 3: dot=28, format is "-ab.c"
+
+	// Byte 0 is either a digit or '-'. Again we can check the 5th bit.
+	minus := ((^x) >> 4) & 1        // 0 if no minus, or 1 if minus.
+	minusMask := (minus - 1) & 0xFF // 0xFF if no minus, or 0 of minus.
+	x = (x & (0xFFFFFFFF00 | minusMask)) << (28 - dot)
+	valUnsigned := ((x>>8)&0x0F)*100 + ((x>>16)&0x0F)*10 + (x>>32)&0x0F
+	return int64(valUnsigned ^ (-minus) + minus), uintptr(dot) >> 3
+}
@@ -0,0 +1,26 @@
+//go:build !purego
+
+package sol4
+
+import "unsafe"
+
+// Parse parses the line at b[i:] and returns the hash of the station name,
+// the temperature in tenths, and the length of the name and of the line.
+// It loads whole words without bounds checks, assuming a little-endian
+// machine and readable bytes behind the line, see common.Padding.
+func Parse(b []byte, i uintptr) (hash uint64, val int64, nameLen, lineLen uintptr) {
+	ptr := unsafe.Add(unsafe.Pointer(unsafe.SliceData(b)), i)
+	sep := unsafe.Add(ptr, 1)
+	for ; *(*byte)(sep) != ';'; sep = unsafe.Add(sep, 1) {
+	}
+	nameLen = uintptr(sep) - uintptr(ptr)
+
+	for ; uintptr(ptr)+8 < uintptr(sep); ptr = unsafe.Add(ptr, 8) {
+		hash ^= *(*uint64)(ptr)
+		hash *= 7
+	}
+	hash ^= *(*uint64)(ptr) & ((1 << ((uintptr(sep) - uintptr(ptr)) * 8)) - 1)
+
+	val, n := temperature(*(*uint64)(unsafe.Add(sep, 1)))
+	return hash, val, nameLen, nameLen + 4 + n
+}
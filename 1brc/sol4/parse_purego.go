@@ -0,0 +1,10 @@
+//go:build purego
+
+package sol4
+
+// Parse parses the line at b[i:] and returns the hash of the station name,
+// the temperature in tenths, and the length of the name and of the line,
+// see ParsePortable.
+func Parse(b []byte, i uintptr) (hash uint64, val int64, nameLen, lineLen uintptr) {
+	return ParsePortable(b, i)
+}
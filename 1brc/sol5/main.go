@@ -0,0 +1,183 @@
+// Package sol5 finds the separators and line ends of a whole block in one
+// pass, 32 bytes at a time with AVX2 where available, then walks the bit
+// masks instead of the bytes.
+package sol5
+
+import (
+	"hash/maphash"
+	"log"
+	"math/bits"
+	"runtime"
+
+	"github.com/draculaas/1brc/common"
+)
+
+const buckets = 1 << 16
+
+var seed = maphash.MakeSeed()
+
+type record struct {
+	name                 string
+	hash                 uint64
+	min, max, sum, count int64
+}
+
+// table is an open addressing table of the stations, a slot is free while
+// its count is 0.
+type table struct {
+	slots [buckets]record
+}
+
+func (t *table) slot(name []byte, hash uint64) *record {
+	for i := hash % buckets; ; i = (i + 1) % buckets {
+		r := &t.slots[i]
+		if r.count == 0 || (r.hash == hash && r.name == string(name)) {
+			return r
+		}
+	}
+}
+
+func (t *table) add(name []byte, val int64) {
+	hash := maphash.Bytes(seed, name)
+	r := t.slot(name, hash)
+	if r.count == 0 {
+		*r = record{name: string(name), hash: hash, min: val, max: val, sum: val, count: 1}
+		return
+	}
+	r.min = min(r.min, val)
+	r.max = max(r.max, val)
+	r.sum += val
+	r.count++
+}
+
+func (t *table) merge(x *record) {
+	r := t.slot([]byte(x.name), x.hash)
+	if r.count == 0 {
+		*r = *x
+		return
+	}
+	r.min = min(r.min, x.min)
+	r.max = max(r.max, x.max)
+	r.sum += x.sum
+	r.count += x.count
+}
+
+type worker struct {
+	t               table
+	semis, newlines []uint64
+}
+
+// process aggregates the complete lines in b, taking the positions of the
+// separators and line ends from the masks of Scan. Names contain neither, so
+// the i-th ';' and the i-th '\n' belong to the i-th line.
+func (w *worker) process(b []byte) {
+	if len(b) == 0 {
+		return
+	}
+	n := (len(b) + 63) / 64
+	if len(w.semis) < n {
+		w.semis = make([]uint64, n)
+		w.newlines = make([]uint64, n)
+	}
+	semis, newlines := w.semis[:n], w.newlines[:n]
+	scan(b, semis, newlines)
+
+	si, ni := 0, 0
+	sm, nm := semis[0], newlines[0]
+	for start := 0; start < len(b); {
+		for sm == 0 {
+			si++
+			sm = semis[si]
+		}
+		sep := si<<6 + bits.TrailingZeros64(sm)
+		sm &= sm - 1
+		for nm == 0 {
+			ni++
+			nm = newlines[ni]
+		}
+		end := ni<<6 + bits.TrailingZeros64(nm)
+		nm &= nm - 1
+
+		w.t.add(b[start:sep], temperature(b[sep+1:end]))
+		start = end + 1
+	}
+}
+
+// temperature parses one of the formats "a.b", "ab.c", "-a.b" and "-ab.c"
+// into tenths.
+func temperature(num []byte) int64 {
+	neg := num[0] == '-'
+	if neg {
+		num = num[1:]
+	}
+	var v int64
+	if len(num) == 4 {
+		v = int64(num[0]-'0')*100 + int64(num[1]-'0')*10 + int64(num[3]-'0')
+	} else {
+		v = int64(num[0]-'0')*10 + int64(num[2]-'0')
+	}
+	if neg {
+		return -v
+	}
+	return v
+}
+
+func Run(fileName string) string {
+	return RunWith(fileName, common.Options{})
+}
+
+// RunWith solves fileName mapped at once, see RunSource.
+func RunWith(fileName string, opts common.Options) string {
+	opts = common.Tuned("sol5", opts)
+	src, err := common.OpenSource("mmap", fileName)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer src.Close()
+	return RunSource(src, opts)
+}
+
+// RunSource solves the file read from src, scanning blocks of
+// opts.ChunkSize bytes on opts.Workers goroutines. Zero options are taken
+// from the tuning file of the machine, see common.Tuned.
+func RunSource(src common.BlockSource, opts common.Options) string {
+	opts = common.Tuned("sol5", opts)
+	numGoroutines := runtime.GOMAXPROCS(0)
+	if opts.Workers > 0 {
+		numGoroutines = opts.Workers
+	}
+
+	workers := make([]*worker, numGoroutines)
+	for i := range workers {
+		workers[i] = new(worker)
+	}
+	err := common.ForEachBlock(src, opts.ChunkSize, numGoroutines, func(i int, lines []byte) {
+		workers[i].process(lines)
+	})
+	if err != nil {
+		log.Fatalf("Failed to read the file %v", err)
+	}
+
+	return result(workers, opts)
+}
+
+// result merges the tables of all the workers into the first one and formats
+// the result.
+func result(workers []*worker, opts common.Options) string {
+	t := &workers[0].t
+	for _, w := range workers[1:] {
+		for i := range w.t.slots {
+			if w.t.slots[i].count > 0 {
+				t.merge(&w.t.slots[i])
+			}
+		}
+	}
+
+	var res common.Result
+	for i := range t.slots {
+		if r := &t.slots[i]; r.count > 0 {
+			res = append(res, common.Station{Name: r.name, Min: r.min, Max: r.max, Sum: r.sum, Count: r.count})
+		}
+	}
+	return common.Finish(res, opts)
+}
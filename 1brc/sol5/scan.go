@@ -0,0 +1,40 @@
+package sol5
+
+// scan is the scanner used by the solver, replaced by the AVX2 one when the
+// CPU has it.
+var scan = ScanGeneric
+
+// Scan sets bit i%64 of semis[i/64] and of newlines[i/64] for every ';' and
+// '\n' at b[i], clearing the other bits. The mask slices must hold at least
+// (len(b)+63)/64 words. Scan uses AVX2 when the CPU has it and may then read
+// up to 63 bytes past len(b), see common.Padding; the bits of those bytes
+// are undefined.
+func Scan(b []byte, semis, newlines []uint64) {
+	n := (len(b) + 63) / 64
+	if len(semis) < n || len(newlines) < n {
+		panic("sol5: mask slices too short for the scanned bytes")
+	}
+	scan(b, semis, newlines)
+}
+
+// Accelerated reports whether Scan runs the AVX2 scanner.
+func Accelerated() bool {
+	return accelerated
+}
+
+// ScanGeneric is the portable version of Scan, never reading past len(b)
+// and clearing the bits past it.
+func ScanGeneric(b []byte, semis, newlines []uint64) {
+	for k := 0; k*64 < len(b); k++ {
+		var semi, nl uint64
+		for i, c := range b[k*64 : min(k*64+64, len(b))] {
+			switch c {
+			case ';':
+				semi |= 1 << i
+			case '\n':
+				nl |= 1 << i
+			}
+		}
+		semis[k], newlines[k] = semi, nl
+	}
+}
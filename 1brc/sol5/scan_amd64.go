@@ -0,0 +1,51 @@
+//go:build amd64 && !purego
+
+package sol5
+
+var accelerated = hasAVX2()
+
+func init() {
+	if accelerated {
+		scan = scanAVX2
+	}
+}
+
+// scanAVX2 runs the assembly scanner over all the 64 byte groups starting
+// in b.
+func scanAVX2(b []byte, semis, newlines []uint64) {
+	if len(b) == 0 {
+		return
+	}
+	scanBlocksAVX2(&b[0], (len(b)+63)/64, &semis[0], &newlines[0])
+}
+
+// scanBlocksAVX2 writes the masks of n groups of 64 bytes at p, loading 32
+// bytes at a time.
+//
+//go:noescape
+func scanBlocksAVX2(p *byte, n int, semis, newlines *uint64)
+
+func cpuid(eaxArg, ecxArg uint32) (eax, ebx, ecx, edx uint32)
+
+func xgetbv() (eax, edx uint32)
+
+// hasAVX2 reports whether the CPU has AVX2 and the OS saves the YMM
+// registers.
+func hasAVX2() bool {
+	maxID, _, _, _ := cpuid(0, 0)
+	if maxID < 7 {
+		return false
+	}
+	_, _, ecx1, _ := cpuid(1, 0)
+	const osxsave, avx = 1 << 27, 1 << 28
+	if ecx1&osxsave == 0 || ecx1&avx == 0 {
+		return false
+	}
+	// XMM and YMM state enabled in XCR0
+	if xcr0, _ := xgetbv(); xcr0&6 != 6 {
+		return false
+	}
+	_, ebx7, _, _ := cpuid(7, 0)
+	const avx2 = 1 << 5
+	return ebx7&avx2 != 0
+}
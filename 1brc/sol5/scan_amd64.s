@@ -0,0 +1,70 @@
+//go:build amd64 && !purego
+
+#include "textflag.h"
+
+// func scanBlocksAVX2(p *byte, n int, semis, newlines *uint64)
+TEXT ·scanBlocksAVX2(SB), NOSPLIT, $0-32
+	MOVQ p+0(FP), SI
+	MOVQ n+8(FP), CX
+	MOVQ semis+16(FP), DI
+	MOVQ newlines+24(FP), DX
+
+	// broadcast ';' to Y0 and '\n' to Y1
+	MOVQ         $0x3b, AX
+	MOVQ         AX, X0
+	VPBROADCASTB X0, Y0
+	MOVQ         $0x0a, AX
+	MOVQ         AX, X1
+	VPBROADCASTB X1, Y1
+
+	TESTQ CX, CX
+	JZ    done
+
+loop:
+	VMOVDQU (SI), Y2
+	VMOVDQU 32(SI), Y3
+
+	VPCMPEQB  Y0, Y2, Y4
+	VPCMPEQB  Y0, Y3, Y5
+	VPMOVMSKB Y4, AX
+	VPMOVMSKB Y5, BX
+	SHLQ      $32, BX
+	ORQ       BX, AX
+	MOVQ      AX, (DI)
+
+	VPCMPEQB  Y1, Y2, Y4
+	VPCMPEQB  Y1, Y3, Y5
+	VPMOVMSKB Y4, AX
+	VPMOVMSKB Y5, BX
+	SHLQ      $32, BX
+	ORQ       BX, AX
+	MOVQ      AX, (DX)
+
+	ADDQ $64, SI
+	ADDQ $8, DI
+	ADDQ $8, DX
+	DECQ CX
+	JNZ  loop
+
+done:
+	VZEROUPPER
+	RET
+
+// func cpuid(eaxArg, ecxArg uint32) (eax, ebx, ecx, edx uint32)
+TEXT ·cpuid(SB), NOSPLIT, $0-24
+	MOVL eaxArg+0(FP), AX
+	MOVL ecxArg+4(FP), CX
+	CPUID
+	MOVL AX, eax+8(FP)
+	MOVL BX, ebx+12(FP)
+	MOVL CX, ecx+16(FP)
+	MOVL DX, edx+20(FP)
+	RET
+
+// func xgetbv() (eax, edx uint32)
+TEXT ·xgetbv(SB), NOSPLIT, $0-8
+	MOVL $0, CX
+	XGETBV
+	MOVL AX, eax+0(FP)
+	MOVL DX, edx+4(FP)
+	RET
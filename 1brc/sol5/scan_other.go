@@ -0,0 +1,5 @@
+//go:build !amd64 || purego
+
+package sol5
+
+const accelerated = false
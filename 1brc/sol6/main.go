@@ -0,0 +1,137 @@
+// Package sol6 aggregates into one table shared by all the workers. Slots
+// are claimed with a compare-and-swap of the hash and updated with atomic
+// operations, so there is no merge phase, at the cost of contention on
+// popular stations.
+package sol6
+
+import (
+	"log"
+	"math"
+	"runtime"
+	"sync/atomic"
+
+	"github.com/draculaas/1brc/common"
+	"github.com/draculaas/1brc/sol4"
+)
+
+const buckets = 1 << 16
+
+// slot is one station of the shared table, identified by the sol4 hash of
+// its name like in sol4. It fills a cache line, so updates of neighbouring
+// stations do not contend.
+type slot struct {
+	hash       atomic.Uint64
+	name       atomic.Pointer[string]
+	min, max   atomic.Int64
+	sum, count atomic.Int64
+	_          [16]byte
+}
+
+type table struct {
+	slots [buckets]slot
+}
+
+func newTable() *table {
+	t := new(table)
+	for i := range t.slots {
+		t.slots[i].min.Store(math.MaxInt64)
+		t.slots[i].max.Store(math.MinInt64)
+	}
+	return t
+}
+
+// find returns the slot of hash, claiming a free one for name if the
+// station is new.
+func (t *table) find(hash uint64, name []byte) *slot {
+	for i := hash % buckets; ; i = (i + 1) % buckets {
+		s := &t.slots[i]
+		h := s.hash.Load()
+		if h == hash {
+			return s
+		}
+		if h == 0 {
+			if s.hash.CompareAndSwap(0, hash) {
+				n := string(name)
+				s.name.Store(&n)
+				return s
+			}
+			// claimed by another worker meanwhile, maybe for the same
+			// station
+			if s.hash.Load() == hash {
+				return s
+			}
+		}
+	}
+}
+
+func (s *slot) add(val int64) {
+	for old := s.min.Load(); val < old && !s.min.CompareAndSwap(old, val); old = s.min.Load() {
+	}
+	for old := s.max.Load(); val > old && !s.max.CompareAndSwap(old, val); old = s.max.Load() {
+	}
+	s.sum.Add(val)
+	s.count.Add(1)
+}
+
+// process aggregates the complete lines in b with the parse loop of sol4.
+func (t *table) process(b []byte) {
+	for start := uintptr(0); start < uintptr(len(b)); {
+		hash, val, nameLen, lineLen := sol4.Parse(b, start)
+		t.find(hash, b[start:start+nameLen]).add(val)
+		start += lineLen
+	}
+}
+
+func Run(fileName string) string {
+	return RunWith(fileName, common.Options{})
+}
+
+// RunWith solves fileName mapped at once, see RunSource.
+func RunWith(fileName string, opts common.Options) string {
+	opts = common.Tuned("sol6", opts)
+	src, err := common.OpenSource("mmap", fileName)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer src.Close()
+	return RunSource(src, opts)
+}
+
+// RunSource solves the file read from src, parsing blocks of opts.ChunkSize
+// bytes on opts.Workers goroutines into the shared table. Zero options are
+// taken from the tuning file of the machine, see common.Tuned.
+func RunSource(src common.BlockSource, opts common.Options) string {
+	opts = common.Tuned("sol6", opts)
+	numGoroutines := runtime.GOMAXPROCS(0)
+	if opts.Workers > 0 {
+		numGoroutines = opts.Workers
+	}
+
+	t := newTable()
+	err := common.ForEachBlock(src, opts.ChunkSize, numGoroutines, func(_ int, lines []byte) {
+		t.process(lines)
+	})
+	if err != nil {
+		log.Fatalf("Failed to read the file %v", err)
+	}
+
+	return result(t, opts)
+}
+
+// result formats the stations of the table, all workers done.
+func result(t *table, opts common.Options) string {
+	var res common.Result
+	for i := range t.slots {
+		s := &t.slots[i]
+		if s.hash.Load() != 0 {
+			res = append(res, common.Station{
+				Name:  *s.name.Load(),
+				Min:   s.min.Load(),
+				Max:   s.max.Load(),
+				Sum:   s.sum.Load(),
+				Count: s.count.Load(),
+			})
+		}
+	}
+	return common.Finish(res, opts)
+}
@@ -0,0 +1,57 @@
+package solver
+
+import (
+	"fmt"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/draculaas/1brc/agg"
+	"github.com/draculaas/1brc/common"
+	"github.com/draculaas/1brc/sol3"
+	"github.com/draculaas/1brc/sol4"
+)
+
+// Aggregates lists the aggregate specs LookupAggregate takes.
+var Aggregates = []string{"min-mean-max", "count-above:<degrees>", "first-last", "distinct"}
+
+// AggregateSolutions lists the solutions whose parse loop computes any
+// aggregate.
+var AggregateSolutions = []string{"sol3", "sol4"}
+
+// LookupAggregate returns the parse loop of sol, one of AggregateSolutions,
+// computing the aggregate of spec, one of Aggregates, e.g. "count-above:30.5".
+func LookupAggregate(sol, spec string) (SourceFunc, error) {
+	if !slices.Contains(AggregateSolutions, sol) {
+		return nil, fmt.Errorf("solution %q computes no other aggregate, want one of %v", sol, AggregateSolutions)
+	}
+	name, arg, _ := strings.Cut(spec, ":")
+	switch name {
+	case "min-mean-max":
+		return aggregateOf(sol, agg.MinMeanMax{}), nil
+	case "count-above":
+		degrees, err := strconv.ParseFloat(arg, 64)
+		if err != nil {
+			return nil, fmt.Errorf("count-above wants a threshold in degrees: %v", err)
+		}
+		return aggregateOf(sol, agg.CountAbove{Threshold: int64(math.Round(degrees * 10))}), nil
+	case "first-last":
+		return aggregateOf(sol, agg.FirstLast{}), nil
+	case "distinct":
+		return aggregateOf(sol, agg.Distinct{}), nil
+	}
+	return nil, fmt.Errorf("unknown aggregate %q, want one of %v", spec, Aggregates)
+}
+
+// aggregateOf returns RunAggregate of sol for proto.
+func aggregateOf[A any, P agg.Aggregator[A]](sol string, proto A) SourceFunc {
+	if sol == "sol3" {
+		return func(src common.BlockSource, opts common.Options) string {
+			return sol3.RunAggregate[A, P](src, opts, proto)
+		}
+	}
+	return func(src common.BlockSource, opts common.Options) string {
+		return sol4.RunAggregate[A, P](src, opts, proto)
+	}
+}
@@ -0,0 +1,41 @@
+package solver
+
+import (
+	"log"
+	"runtime"
+
+	"github.com/draculaas/1brc/common"
+	"github.com/draculaas/1brc/profile"
+)
+
+func init() {
+	Register("auto", Auto)
+}
+
+// Auto profiles fileName and runs the recommended solution. Non-zero opts
+// override the tuning file of the machine, which overrides the recommended
+// tuning.
+func Auto(fileName string, opts common.Options) string {
+	p, err := profile.File(fileName)
+	if err != nil {
+		log.Fatalf("Failed to profile %s: %v", fileName, err)
+	}
+	r := p.Recommend(runtime.GOMAXPROCS(0))
+	opts = common.Tuned(r.Solution, opts)
+	if opts.Workers > 0 {
+		r.Options.Workers = opts.Workers
+	}
+	if opts.ChunkSize > 0 {
+		r.Options.ChunkSize = opts.ChunkSize
+	}
+	if opts.Mmap != 0 {
+		r.Options.Mmap = opts.Mmap
+	}
+	if opts.Window > 0 {
+		r.Options.Window = opts.Window
+	}
+	r.Options.Report = opts.Report
+	r.Options.Dictionary = opts.Dictionary
+	r.Options.Partial = opts.Partial
+	return registry[r.Solution](fileName, r.Options)
+}
@@ -0,0 +1,86 @@
+// Package solver keeps the registry of solutions, so they can be picked by
+// name from the command line and by other packages.
+package solver
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/draculaas/1brc/columnar"
+	"github.com/draculaas/1brc/common"
+	"github.com/draculaas/1brc/sol1"
+	"github.com/draculaas/1brc/sol2"
+	"github.com/draculaas/1brc/sol3"
+	"github.com/draculaas/1brc/sol4"
+	"github.com/draculaas/1brc/sol5"
+	"github.com/draculaas/1brc/sol6"
+)
+
+// Func solves the measurements file fileName and returns the formatted
+// result.
+type Func func(fileName string, opts common.Options) string
+
+var registry = map[string]Func{
+	"sol1": sol1.RunWith,
+	"sol2": sol2.RunWith,
+	"sol3": sol3.RunWith,
+	"sol4": sol4.RunWith,
+	"sol5": sol5.RunWith,
+	"sol6": sol6.RunWith,
+	// columnar solves a file written by the compile command, not text
+	"columnar": columnar.RunWith,
+}
+
+// SourceFunc solves the measurements file read from src and returns the
+// formatted result.
+type SourceFunc func(src common.BlockSource, opts common.Options) string
+
+var sourceRegistry = map[string]SourceFunc{
+	"sol1": sol1.RunSource,
+	"sol2": sol2.RunSource,
+	"sol3": sol3.RunSource,
+	"sol4": sol4.RunSource,
+	"sol5": sol5.RunSource,
+	"sol6": sol6.RunSource,
+}
+
+// Register adds a solution to the registry, replacing any solution with the
+// same name.
+func Register(name string, f Func) {
+	registry[name] = f
+}
+
+// Lookup returns the solution registered as name.
+func Lookup(name string) (Func, error) {
+	f, ok := registry[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown solution %q, want one of %v", name, Names())
+	}
+	return f, nil
+}
+
+// RegisterSource adds a solution reading from a block source to the
+// registry, replacing any solution with the same name.
+func RegisterSource(name string, f SourceFunc) {
+	sourceRegistry[name] = f
+}
+
+// LookupSource returns the solution registered as name that reads from a
+// block source.
+func LookupSource(name string) (SourceFunc, error) {
+	f, ok := sourceRegistry[name]
+	if !ok {
+		return nil, fmt.Errorf("solution %q cannot read from a block source", name)
+	}
+	return f, nil
+}
+
+// Names returns the names of all the registered solutions, sorted.
+func Names() []string {
+	names := make([]string, 0, len(registry))
+	for name := range registry {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
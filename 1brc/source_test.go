@@ -0,0 +1,84 @@
+package main
+
+import (
+	"sync/atomic"
+	"testing"
+
+	"github.com/draculaas/1brc/common"
+	"github.com/draculaas/1brc/sol3"
+	"github.com/draculaas/1brc/sol4"
+	"github.com/draculaas/1brc/solver"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_TestSources(t *testing.T) {
+	fileNames := find("./test_cases", ".txt")
+	for _, kind := range common.SourceKinds() {
+		for _, sol := range []string{"sol1", "sol2", "sol3", "sol4", "sol5", "sol6"} {
+			run, err := solver.LookupSource(sol)
+			assert.NoError(t, err)
+			for _, name := range fileNames {
+				t.Run(kind+"/"+sol+"/"+name, func(t *testing.T) {
+					src, err := common.OpenSource(kind, name+".txt")
+					if err != nil {
+						t.Skipf("%s source unavailable: %v", kind, err)
+					}
+					defer src.Close()
+					// small blocks, so most lines are close to a block boundary
+					got := run(src, common.Options{Workers: 2, ChunkSize: 64})
+					assert.Equal(t, readFile(name+".out"), got)
+				})
+			}
+		}
+	}
+}
+
+// BenchmarkSources measures every block source on warm and cold page cache,
+// once without parsing and once with the parse loops of sol3 and sol4.
+func BenchmarkSources(b *testing.B) {
+	fileName := writeMeasurements(b, randomNames(10_000), 2_000_000)
+
+	parsers := []struct {
+		name string
+		run  solver.SourceFunc
+	}{
+		// touch every page, so mmap faults them in like the parsers do
+		{"none", func(src common.BlockSource, opts common.Options) string {
+			var touched atomic.Uint64
+			common.ForEachBlock(src, opts.ChunkSize, opts.Workers, func(_ int, lines []byte) {
+				var sum uint64
+				for i := 0; i < len(lines); i += 4096 {
+					sum += uint64(lines[i])
+				}
+				touched.Add(sum)
+			})
+			return ""
+		}},
+		{"sol3", sol3.RunSource},
+		{"sol4", sol4.RunSource},
+	}
+
+	for _, kind := range common.SourceKinds() {
+		for _, cache := range []string{"warm", "cold"} {
+			for _, p := range parsers {
+				b.Run(kind+"/"+cache+"/"+p.name, func(b *testing.B) {
+					for i := 0; i < b.N; i++ {
+						if cache == "cold" {
+							b.StopTimer()
+							if err := common.Evict(fileName); err != nil {
+								b.Skip(err)
+							}
+							b.StartTimer()
+						}
+						src, err := common.OpenSource(kind, fileName)
+						if err != nil {
+							b.Skip(err)
+						}
+						p.run(src, common.Options{})
+						src.Close()
+					}
+				})
+			}
+		}
+	}
+}
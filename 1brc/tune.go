@@ -0,0 +1,100 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"runtime"
+	"slices"
+	"time"
+
+	"github.com/draculaas/1brc/common"
+	"github.com/draculaas/1brc/solver"
+)
+
+// tunables are the solutions the tune command searches options for, and
+// whether they read the file in chunks.
+var tunables = []struct {
+	name    string
+	chunked bool
+}{
+	{"sol2", true},
+	{"sol3", true},
+	{"sol4", true},
+	{"sol5", true},
+	{"sol6", true},
+}
+
+func tuneCmd(args []string) error {
+	fs := newFlagSet("tune", "tune [flags] <sample file>")
+	runs := fs.Int("runs", 3, "runs of every setting, the fastest one counts")
+	out := fs.String("out", common.TuningFile(), "tuning `file` to write")
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		fs.Usage()
+		os.Exit(2)
+	}
+	fileName := fs.Arg(0)
+
+	tuning, err := common.LoadTuning(*out)
+	if err != nil {
+		return err
+	}
+
+	var workers []int
+	for n := 1; n < 2*runtime.NumCPU(); n *= 2 {
+		workers = append(workers, n)
+	}
+	// NumCPU is already there when a power of two
+	if !slices.Contains(workers, runtime.NumCPU()) {
+		workers = append(workers, runtime.NumCPU())
+	}
+	workers = append(workers, 2*runtime.NumCPU())
+	var chunkSizes []int
+	for size := 64 << 10; size <= 8<<20; size *= 2 {
+		chunkSizes = append(chunkSizes, size)
+	}
+
+	for _, t := range tunables {
+		run, err := solver.Lookup(t.name)
+		if err != nil {
+			return err
+		}
+		measure := func(opts common.Options) time.Duration {
+			best := time.Duration(1<<63 - 1)
+			for i := 0; i < *runs; i++ {
+				start := time.Now()
+				run(fileName, opts)
+				best = min(best, time.Since(start))
+			}
+			fmt.Printf("%s -workers %d -chunk-size %d: %v\n", t.name, opts.Workers, opts.ChunkSize, best)
+			return best
+		}
+
+		// search one dimension at a time: the worker count with the
+		// current chunk size, then the chunk size with the best worker count
+		best := common.Options{}
+		bestTime := time.Duration(1<<63 - 1)
+		for _, n := range workers {
+			opts := common.Options{Workers: n, ChunkSize: best.ChunkSize}
+			if d := measure(opts); d < bestTime {
+				best, bestTime = opts, d
+			}
+		}
+		if t.chunked {
+			for _, size := range chunkSizes {
+				opts := common.Options{Workers: best.Workers, ChunkSize: size}
+				if d := measure(opts); d < bestTime {
+					best, bestTime = opts, d
+				}
+			}
+		}
+		fmt.Printf("%s: best -workers %d -chunk-size %d in %v\n", t.name, best.Workers, best.ChunkSize, bestTime)
+		tuning[t.name] = best
+	}
+
+	if err := tuning.Save(*out); err != nil {
+		return err
+	}
+	fmt.Printf("Wrote %s\n", *out)
+	return nil
+}
@@ -0,0 +1,26 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/draculaas/1brc/common"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_TestTuningFile(t *testing.T) {
+	fileName := filepath.Join(t.TempDir(), "1brc", "tuning.json")
+
+	missing, err := common.LoadTuning(fileName)
+	assert.NoError(t, err)
+	assert.Empty(t, missing)
+
+	want := common.Tuning{
+		"sol2": {Workers: 3},
+		"sol4": {Workers: 5, ChunkSize: 1 << 21},
+	}
+	assert.NoError(t, want.Save(fileName))
+	got, err := common.LoadTuning(fileName)
+	assert.NoError(t, err)
+	assert.Equal(t, want, got)
+}
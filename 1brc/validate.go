@@ -0,0 +1,30 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/draculaas/1brc/validate"
+)
+
+func validateCmd(args []string) error {
+	fs := newFlagSet("validate", "validate <file>")
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	fileName := fs.Arg(0)
+	summary, err := validate.File(fileName, func(v validate.Violation) {
+		fmt.Printf("%s:%d: %s\n", fileName, v.Line, v.Msg)
+	})
+	if err != nil {
+		return err
+	}
+	fmt.Printf("%s: %s\n", fileName, summary)
+	if summary.Violations > 0 {
+		return fmt.Errorf("%s breaks the rules %d times", fileName, summary.Violations)
+	}
+	return nil
+}
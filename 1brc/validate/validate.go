@@ -0,0 +1,178 @@
+// Package validate checks measurement files against the rules of the
+// challenge, see Readme.md.
+package validate
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"unicode/utf8"
+)
+
+const (
+	MaxNameLen  = 100
+	MaxStations = 10_000
+)
+
+// Violation is a broken rule on a line of the file, counted from 1.
+type Violation struct {
+	Line int64
+	Msg  string
+}
+
+// Summary describes a validated file.
+type Summary struct {
+	Lines      int64
+	Stations   int
+	Violations int64
+}
+
+func (s Summary) String() string {
+	return fmt.Sprintf("%d lines, %d unique stations, %d violations", s.Lines, s.Stations, s.Violations)
+}
+
+// File validates the measurements file fileName, calling report for every
+// violation found.
+func File(fileName string, report func(Violation)) (Summary, error) {
+	f, err := os.Open(fileName)
+	if err != nil {
+		return Summary{}, err
+	}
+	defer f.Close()
+	return Reader(f, report)
+}
+
+// Reader validates the measurements read from r, calling report for every
+// violation found.
+func Reader(r io.Reader, report func(Violation)) (Summary, error) {
+	var s Summary
+	stations := make(map[string]struct{}, MaxStations)
+	br := bufio.NewReaderSize(r, 1<<20)
+
+	for {
+		line, err := br.ReadSlice('\n')
+		// a line longer than the buffer can never be valid, report it once
+		// and skip the rest of it
+		long := false
+		for err == bufio.ErrBufferFull {
+			long = true
+			_, err = br.ReadSlice('\n')
+		}
+		if err != nil && err != io.EOF {
+			return s, err
+		}
+		if len(line) == 0 && err == io.EOF {
+			break
+		}
+
+		s.Lines++
+		fail := func(format string, args ...any) {
+			s.Violations++
+			report(Violation{Line: s.Lines, Msg: fmt.Sprintf(format, args...)})
+		}
+
+		if long {
+			fail("line is longer than %d bytes", br.Size())
+			continue
+		}
+		if line[len(line)-1] == '\n' {
+			line = line[:len(line)-1]
+		} else {
+			fail("missing \\n at the end of the file")
+		}
+		if len(line) > 0 && line[len(line)-1] == '\r' {
+			fail("line ends with \\r\\n instead of \\n")
+			line = line[:len(line)-1]
+		}
+		if name, ok := checkLine(line, fail); ok {
+			if _, seen := stations[string(name)]; !seen {
+				stations[string(name)] = struct{}{}
+				if len(stations) > MaxStations {
+					fail("station %q is unique station number %d, more than %d", name, len(stations), MaxStations)
+				}
+			}
+		}
+
+		if err == io.EOF {
+			break
+		}
+	}
+
+	s.Stations = len(stations)
+	return s, nil
+}
+
+// Lines checks the lines in b, which all end in \n, and returns the first
+// violation found, its line counted from 1 in b. Unlike Reader it does not
+// count the stations.
+func Lines(b []byte) (Violation, bool) {
+	var v Violation
+	failed := false
+	fail := func(format string, args ...any) {
+		if !failed {
+			failed = true
+			v.Msg = fmt.Sprintf(format, args...)
+		}
+	}
+	for len(b) > 0 {
+		v.Line++
+		end := bytes.IndexByte(b, '\n')
+		if end < 0 {
+			return Violation{Line: v.Line, Msg: "missing \\n at the end of the file"}, false
+		}
+		checkLine(b[:end], fail)
+		if failed {
+			return v, false
+		}
+		b = b[end+1:]
+	}
+	return Violation{}, true
+}
+
+// checkLine validates a line without its line ending and returns the station
+// name if it is valid.
+func checkLine(line []byte, fail func(format string, args ...any)) ([]byte, bool) {
+	sep := bytes.IndexByte(line, ';')
+	if sep < 0 {
+		fail("missing ';' separator")
+		return nil, false
+	}
+	name, temp := line[:sep], line[sep+1:]
+
+	ok := true
+	if len(name) == 0 || len(name) > MaxNameLen {
+		fail("station name is %d bytes long, want 1..%d", len(name), MaxNameLen)
+		ok = false
+	}
+	if !utf8.Valid(name) {
+		fail("station name %q is not valid UTF-8", name)
+		ok = false
+	}
+	if !validTemp(temp) {
+		fail("temperature %q is not within -99.9..99.9 with exactly one fractional digit", temp)
+	}
+	return name, ok
+}
+
+// validTemp reports whether b is a number in -99.9..99.9 written with one or
+// two integer digits and exactly one fractional digit.
+func validTemp(b []byte) bool {
+	if len(b) > 0 && b[0] == '-' {
+		b = b[1:]
+	}
+	if len(b) != 3 && len(b) != 4 {
+		return false
+	}
+	for i, c := range b {
+		if i == len(b)-2 {
+			if c != '.' {
+				return false
+			}
+		} else if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return true
+}
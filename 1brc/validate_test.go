@@ -0,0 +1,59 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/draculaas/1brc/validate"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_TestValidateTestCases(t *testing.T) {
+	for _, name := range find("./test_cases", ".txt") {
+		t.Run(name, func(t *testing.T) {
+			summary, err := validate.File(name+".txt", func(v validate.Violation) {
+				t.Errorf("line %d: %s", v.Line, v.Msg)
+			})
+			assert.NoError(t, err)
+			assert.Zero(t, summary.Violations)
+		})
+	}
+}
+
+func Test_TestValidate(t *testing.T) {
+	input := "ok;1.0\r\n" +
+		"no separator\n" +
+		";1.0\n" +
+		strings.Repeat("x", 101) + ";1.0\n" +
+		"hot;100.0\n" +
+		"precise;1.05\n" +
+		"whole;-1\n" +
+		"\xff\xfe;1.0\n" +
+		"ok;-99.9\n" +
+		"last;2.0"
+
+	var lines []int64
+	summary, err := validate.Reader(strings.NewReader(input), func(v validate.Violation) {
+		lines = append(lines, v.Line)
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, []int64{1, 2, 3, 4, 5, 6, 7, 8, 10}, lines)
+	assert.Equal(t, validate.Summary{Lines: 10, Stations: 5, Violations: 9}, summary)
+}
+
+func Test_TestValidateTooManyStations(t *testing.T) {
+	var sb strings.Builder
+	for i := 0; i < validate.MaxStations+2; i++ {
+		fmt.Fprintf(&sb, "s%d;1.0\n", i)
+	}
+	sb.WriteString("s0;1.0\n")
+
+	var lines []int64
+	summary, err := validate.Reader(strings.NewReader(sb.String()), func(v validate.Violation) {
+		lines = append(lines, v.Line)
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, []int64{validate.MaxStations + 1, validate.MaxStations + 2}, lines)
+	assert.Equal(t, validate.MaxStations+2, summary.Stations)
+}